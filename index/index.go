@@ -0,0 +1,279 @@
+// Package index maintains secondary indexes over the entries of a store.Store.
+//
+// Index entries are stored in the same store as the primary entries, under keys starting with
+// "\xff\xffindex/", which are reserved.
+package index
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("kdb/index")
+
+var entryKeyPrefix = []byte("\xff\xffindex/")
+
+// lookupBatchLen is the number of primary entries read at a time by `LookupBy`.
+const lookupBatchLen = 500
+
+// Extractor returns the index keys of a primary entry, none when it is not indexed.
+type Extractor func(key, value []byte) [][]byte
+
+// Store writes primary entries along with the entries of their indexes.
+//
+// When the underlying store is `store.Transactional`, a primary entry and its index entries are
+// updated in a transaction. Otherwise the new index entries are written along with the primary entry
+// and flushed, before the stale ones are deleted: a lookup may then briefly return an entry which no
+// longer matches, but never misses one.
+type Store struct {
+	st      store.Store
+	indexes map[string]Extractor
+}
+
+// New returns a Store maintaining the given indexes, by name.
+func New(st store.Store, indexes map[string]Extractor) *Store {
+	return &Store{st: st, indexes: indexes}
+}
+
+// entryPrefix returns the prefix of the entries of `indexKey` in the index `name`, the lengths of both
+// being encoded so that entries cannot collide.
+func entryPrefix(name string, indexKey []byte) []byte {
+	out := make([]byte, len(entryKeyPrefix)+len(name)+len(indexKey)+2*binary.MaxVarintLen64)
+	n := copy(out, entryKeyPrefix)
+	n += binary.PutUvarint(out[n:], uint64(len(name)))
+	n += copy(out[n:], name)
+	n += binary.PutUvarint(out[n:], uint64(len(indexKey)))
+	n += copy(out[n:], indexKey)
+	return out[:n]
+}
+
+func entryKey(name string, indexKey, key []byte) []byte {
+	return append(entryPrefix(name, indexKey), key...)
+}
+
+// entries returns the keys of the index entries of a primary entry.
+func (s *Store) entries(key, value []byte) map[string][]byte {
+	out := make(map[string][]byte)
+	for name, extract := range s.indexes {
+		for _, indexKey := range extract(key, value) {
+			entry := entryKey(name, indexKey, key)
+			out[string(entry)] = entry
+		}
+	}
+	return out
+}
+
+// diff returns the index entries to delete and to add when the value of a primary entry changes, a
+// nil value standing for a missing entry.
+func (s *Store) diff(key, old, value []byte) (stale, added [][]byte) {
+	before, after := map[string][]byte{}, map[string][]byte{}
+	if old != nil {
+		before = s.entries(key, old)
+	}
+	if value != nil {
+		after = s.entries(key, value)
+	}
+
+	for k, entry := range before {
+		if _, ok := after[k]; !ok {
+			stale = append(stale, entry)
+		}
+	}
+	for k, entry := range after {
+		if _, ok := before[k]; !ok {
+			added = append(added, entry)
+		}
+	}
+	return stale, added
+}
+
+// Get a given primary key.  Returns `kdb.ErrNotFound` if not found.
+func (s *Store) Get(ctx context.Context, key []byte) (value []byte, err error) {
+	return s.st.Get(ctx, key)
+}
+
+// Put writes a primary entry and updates its index entries.  Unlike `store.Store.Put`, the write is
+// applied when Put returns.
+func (s *Store) Put(ctx context.Context, key, value []byte) (err error) {
+	if value == nil {
+		value = []byte{}
+	}
+	return s.update(ctx, key, value)
+}
+
+// Delete a primary entry and its index entries.  Returns `kdb.ErrNotFound` if not found.
+func (s *Store) Delete(ctx context.Context, key []byte) (err error) {
+	return s.update(ctx, key, nil)
+}
+
+// update replaces the value of a primary entry, deleting it when value is nil.
+func (s *Store) update(ctx context.Context, key, value []byte) error {
+	log.Debugw("updating", "key", store.Key(key), "deleted", value == nil)
+
+	if txnStore, ok := s.st.(store.Transactional); ok {
+		return store.RunTxn(ctx, txnStore, func(txn store.Txn) error {
+			old, err := getOld(txn.Get(ctx, key))
+			if err != nil {
+				return err
+			}
+			if old == nil && value == nil {
+				return store.ErrNotFound
+			}
+
+			if value != nil {
+				err = txn.Put(ctx, key, value)
+			} else {
+				err = txn.Delete(ctx, key)
+			}
+			if err != nil {
+				return err
+			}
+
+			stale, added := s.diff(key, old, value)
+			for _, entry := range stale {
+				if err := txn.Delete(ctx, entry); err != nil {
+					return err
+				}
+			}
+			for _, entry := range added {
+				if err := txn.Put(ctx, entry, []byte{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	old, err := getOld(s.st.Get(ctx, key))
+	if err != nil {
+		return err
+	}
+	if old == nil && value == nil {
+		return store.ErrNotFound
+	}
+
+	stale, added := s.diff(key, old, value)
+	if value != nil {
+		if err := s.st.Put(ctx, key, value); err != nil {
+			return err
+		}
+	}
+	for _, entry := range added {
+		if err := s.st.Put(ctx, entry, []byte{}); err != nil {
+			return err
+		}
+	}
+	if err := s.st.FlushPuts(ctx); err != nil {
+		return err
+	}
+
+	if value == nil {
+		if err := s.st.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	return s.st.BatchDelete(ctx, stale)
+}
+
+// getOld returns the current value of a primary entry, nil when it does not exist.
+func getOld(value []byte, err error) ([]byte, error) {
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+// LookupBy returns the primary entries which index `indexName` holds `indexKey`, in the order of their
+// keys.  The entries deleted since the index was read are skipped.
+func (s *Store) LookupBy(ctx context.Context, indexName string, indexKey []byte) *store.Iterator {
+	log.Debugw("looking up", "index", indexName, "index_key", store.Key(indexKey))
+
+	out := store.NewIterator(ctx)
+	if _, ok := s.indexes[indexName]; !ok {
+		out.PushError(fmt.Errorf("unknown index %q", indexName))
+		return out
+	}
+
+	go func() {
+		prefix := entryPrefix(indexName, indexKey)
+		var keys [][]byte
+		err := store.ForEach(s.st.Prefix(ctx, prefix, 0, store.KeyOnly()), func(key, _ []byte) error {
+			keys = append(keys, key[len(prefix):])
+			return nil
+		})
+		if err != nil {
+			out.PushError(err)
+			return
+		}
+		for len(keys) > 0 {
+			n := len(keys)
+			if n > lookupBatchLen {
+				n = lookupBatchLen
+			}
+			items, err := s.lookup(ctx, keys[:n])
+			if err != nil {
+				out.PushError(err)
+				return
+			}
+			for _, item := range items {
+				if !out.PushItem(item) {
+					return
+				}
+			}
+			keys = keys[n:]
+		}
+		out.PushFinished()
+	}()
+	return out
+}
+
+// lookup reads the primary entries of keys, skipping the missing ones.  With `store.AllowMissing`, an
+// empty value cannot be told apart from a missing key, so the keys read without a value are checked
+// with `BatchHas`.
+func (s *Store) lookup(ctx context.Context, keys [][]byte) (items []store.KV, err error) {
+	var empty [][]byte
+	err = store.ForEach(s.st.BatchGet(ctx, keys, store.AllowMissing()), func(key, value []byte) error {
+		if value == nil {
+			empty = append(empty, key)
+		}
+		items = append(items, store.KV{Key: key, Value: value})
+		return nil
+	})
+	if err != nil || len(empty) == 0 {
+		return items, err
+	}
+
+	exists, err := s.st.BatchHas(ctx, empty)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(empty))
+	for i, key := range empty {
+		found[string(key)] = exists[i]
+	}
+
+	out := items[:0]
+	for _, item := range items {
+		if item.Value == nil {
+			if !found[string(item.Key)] {
+				continue
+			}
+			item.Value = []byte{}
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
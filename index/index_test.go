@@ -0,0 +1,118 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/bitrainforest/kdb/store/badger"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+func makeStore(t *testing.T) store.Store {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "badger")
+	require.NoError(t, err)
+	st, err := badger.NewStore(dir)
+	require.NoError(t, err)
+	return st
+}
+
+// byOwner indexes "owner|payload" values by owner.
+func byOwner(_, value []byte) [][]byte {
+	owner, _, found := bytes.Cut(value, []byte("|"))
+	if !found {
+		return nil
+	}
+	return [][]byte{owner}
+}
+
+func lookupKeys(t *testing.T, s *Store, owner string) (keys []string) {
+	items, err := s.LookupBy(context.TODO(), "owner", []byte(owner)).Collect()
+	require.NoError(t, err)
+	for _, item := range items {
+		keys = append(keys, string(item.Key))
+	}
+	return keys
+}
+
+func TestStore(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	for name, underlying := range map[string]store.Store{
+		"transactional": st,
+		// The namespace wrapper does not forward transactions
+		"plain": store.WithNamespace(st, "plain/"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := New(underlying, map[string]Extractor{"owner": byOwner})
+
+			require.NoError(t, s.Put(ctx, []byte("deal/1"), []byte("f01|a")))
+			require.NoError(t, s.Put(ctx, []byte("deal/2"), []byte("f02|b")))
+			require.NoError(t, s.Put(ctx, []byte("deal/3"), []byte("f01|c")))
+			require.Equal(t, []string{"deal/1", "deal/3"}, lookupKeys(t, s, "f01"))
+
+			// Changing the owner moves the entry
+			require.NoError(t, s.Put(ctx, []byte("deal/1"), []byte("f02|a")))
+			require.Equal(t, []string{"deal/3"}, lookupKeys(t, s, "f01"))
+			require.Equal(t, []string{"deal/1", "deal/2"}, lookupKeys(t, s, "f02"))
+
+			require.NoError(t, s.Delete(ctx, []byte("deal/2")))
+			require.ErrorIs(t, s.Delete(ctx, []byte("deal/2")), store.ErrNotFound)
+			require.Equal(t, []string{"deal/1"}, lookupKeys(t, s, "f02"))
+
+			value, err := s.Get(ctx, []byte("deal/1"))
+			require.NoError(t, err)
+			require.Equal(t, "f02|a", string(value))
+
+			items, err := s.LookupBy(ctx, "owner", []byte("f01")).Collect()
+			require.NoError(t, err)
+			require.Equal(t, []store.KV{{Key: []byte("deal/3"), Value: []byte("f01|c")}}, items)
+
+			_, err = s.LookupBy(ctx, "size", []byte("1")).Collect()
+			require.Error(t, err)
+
+			// No stale entry is left behind
+			n, err := underlying.Count(ctx, entryKeyPrefix)
+			require.NoError(t, err)
+			require.Equal(t, int64(2), n)
+		})
+	}
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_EmptyValue(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	// byKind indexes "kind/id" keys by kind, whatever their value
+	byKind := func(key, _ []byte) [][]byte {
+		kind, _, _ := bytes.Cut(key, []byte("/"))
+		return [][]byte{kind}
+	}
+	s := New(st, map[string]Extractor{"kind": byKind})
+
+	require.NoError(t, s.Put(ctx, []byte("flag/1"), []byte{}))
+	require.NoError(t, s.Put(ctx, []byte("flag/2"), nil))
+	require.NoError(t, s.Put(ctx, []byte("flag/3"), []byte("on")))
+
+	items, err := s.LookupBy(ctx, "kind", []byte("flag")).Collect()
+	require.NoError(t, err)
+	require.Equal(t, []store.KV{
+		{Key: []byte("flag/1"), Value: []byte{}},
+		{Key: []byte("flag/2"), Value: []byte{}},
+		{Key: []byte("flag/3"), Value: []byte("on")},
+	}, items)
+
+	// A primary entry deleted behind the index is skipped
+	require.NoError(t, st.Delete(ctx, []byte("flag/1")))
+	items, err = s.LookupBy(ctx, "kind", []byte("flag")).Collect()
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	require.NoError(t, st.Close())
+}
@@ -1,6 +1,7 @@
 package badger
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/bitrainforest/kdb/store"
@@ -8,14 +9,32 @@ import (
 	logging "github.com/ipfs/go-log"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
 var log = logging.Logger("kdb/badger")
 
+const (
+	maxBatchLen   = 10000
+	maxBatchBytes = 8 << 20
+)
+
+// defaultBatchPolicy bounds the writes held in memory by `Put`, unless the DSN says otherwise.
+var defaultBatchPolicy = store.BatchPolicy{Size: maxBatchLen, Bytes: maxBatchBytes}
+
 type Store struct {
+	reader
 	dsn        string
 	db         *badger.DB
-	writeBatch *badger.WriteBatch
+	writeBatch *batch
+	writeLk    sync.Mutex
+	policy     store.BatchPolicy
+	flusher    *store.AutoFlusher
+}
+
+// reader serves the reads of a Store, or of a snapshot, within the transactions provided by view.
+type reader struct {
+	view func(fn func(txn *badger.Txn) error) error
 }
 
 var _ store.Store = (*Store)(nil)
@@ -41,53 +60,69 @@ func NewStore(dsnString string) (store.Store, error) {
 	}
 
 	s := &Store{
-		dsn: dsnString,
-		db:  db,
+		reader: reader{view: db.View},
+		dsn:    dsnString,
+		db:     db,
+		policy: dsn.batch,
 	}
+	s.flusher = store.NewAutoFlusher(dsn.batch.FlushInterval, s.flush)
 	return s, nil
 }
 
 func (s *Store) Close() error {
+	s.flusher.Stop()
 	return s.db.Close()
 }
 
-func (s *Store) Put(_ context.Context, key, value []byte) (err error) {
+var _ store.FlushErrorNotifier = (*Store)(nil)
+
+func (s *Store) OnFlushError(fn func(err error)) {
+	s.flusher.SetErrorHandler(fn)
+}
+
+func (s *Store) Put(ctx context.Context, key, value []byte, options ...store.WriteOption) (err error) {
 	log.Debugw("putting", "key", store.Key(key))
+	s.writeLk.Lock()
+	defer s.writeLk.Unlock()
+
 	if s.writeBatch == nil {
-		s.writeBatch = s.db.NewWriteBatch()
+		s.writeBatch = s.newBatch()
+	}
+	if err := s.writeBatch.Put(ctx, key, value, options...); err != nil {
+		return err
 	}
 
-	err = s.writeBatch.SetEntry(badger.NewEntry(key, value))
-	if err == badger.ErrTxnTooBig {
-		log.Debug("txn too big pre-emptively pushing")
-		if err := s.writeBatch.Flush(); err != nil {
+	if s.policy.Full(s.writeBatch) {
+		if err := s.writeBatch.Commit(ctx); err != nil {
 			return err
 		}
-
-		s.writeBatch = s.db.NewWriteBatch()
-		err := s.writeBatch.SetEntry(badger.NewEntry(key, value))
-		if err != nil {
-			return fmt.Errorf("set entry (after flush): %w", err)
-		}
 	}
+	return s.flusher.Err()
+}
 
-	if err != nil {
-		return fmt.Errorf("set entry: %w", err)
+func newEntry(key, value []byte, writeOptions store.WriteOptions) *badger.Entry {
+	entry := badger.NewEntry(key, value)
+	if writeOptions.TTL > 0 {
+		entry = entry.WithTTL(writeOptions.TTL)
 	}
+	return entry
+}
 
-	return nil
+func (s *Store) FlushPuts(ctx context.Context) error {
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	return s.flusher.Err()
 }
 
-func (s *Store) FlushPuts(_ context.Context) error {
+func (s *Store) flush(ctx context.Context) error {
+	s.writeLk.Lock()
+	defer s.writeLk.Unlock()
+
 	if s.writeBatch == nil {
 		return nil
 	}
-	err := s.writeBatch.Flush()
-	if err != nil {
-		return err
-	}
-	s.writeBatch = s.db.NewWriteBatch()
-	return nil
+	return s.writeBatch.Commit(ctx)
 }
 
 func wrapNotFoundError(err error) error {
@@ -97,8 +132,8 @@ func wrapNotFoundError(err error) error {
 	return err
 }
 
-func (s *Store) Get(_ context.Context, key []byte) (value []byte, err error) {
-	err = s.db.View(func(txn *badger.Txn) error {
+func (r reader) Get(_ context.Context, key []byte) (value []byte, err error) {
+	err = r.view(func(txn *badger.Txn) error {
 		item, err := txn.Get(key)
 		if err != nil {
 			return wrapNotFoundError(err)
@@ -113,6 +148,37 @@ func (s *Store) Get(_ context.Context, key []byte) (value []byte, err error) {
 	return
 }
 
+func (r reader) Has(_ context.Context, key []byte) (exists bool, err error) {
+	err = r.view(func(txn *badger.Txn) error {
+		exists, err = has(txn, key)
+		return err
+	})
+	return
+}
+
+func (r reader) BatchHas(_ context.Context, keys [][]byte) (exists []bool, err error) {
+	log.Debugw("batch has", "key_count", len(keys))
+	exists = make([]bool, len(keys))
+	err = r.view(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			exists[i], err = has(txn, key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func has(txn *badger.Txn, key []byte) (bool, error) {
+	_, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (s *Store) BatchDelete(_ context.Context, keys [][]byte) (err error) {
 	log.Debugw("batch deletion", "key_count", len(keys))
 
@@ -141,13 +207,67 @@ func (s *Store) BatchDelete(_ context.Context, keys [][]byte) (err error) {
 	return deletionBatch.Flush()
 }
 
-func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
+// DeletePrefix relies on badger `DropPrefix`, which blocks writes while it runs.
+func (s *Store) DeletePrefix(_ context.Context, prefix []byte) (err error) {
+	log.Debugw("prefix deletion", "prefix", store.Key(prefix))
+	if len(prefix) == 0 {
+		return s.db.DropAll()
+	}
+	return s.db.DropPrefix(prefix)
+}
+
+func (s *Store) DeleteRange(_ context.Context, start, exclusiveEnd []byte) (err error) {
+	log.Debugw("range deletion", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd))
+
+	deletionBatch := s.db.NewWriteBatch()
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+
+		return iterateRange(txn, opts, nil, start, exclusiveEnd, func(item *badger.Item) (bool, error) {
+			key := item.KeyCopy(nil)
+			err := deletionBatch.Delete(key)
+			if err == badger.ErrTxnTooBig {
+				log.Debug("txn too big pre-emptively pushing")
+				if err := deletionBatch.Flush(); err != nil {
+					return false, err
+				}
+
+				deletionBatch = s.db.NewWriteBatch()
+				err = deletionBatch.Delete(key)
+			}
+			if err != nil {
+				return false, fmt.Errorf("delete: %w", err)
+			}
+			return true, nil
+		})
+	})
+	if err != nil {
+		deletionBatch.Cancel()
+		return err
+	}
+
+	return deletionBatch.Flush()
+}
+
+func (r reader) BatchGet(ctx context.Context, keys [][]byte, options ...store.ReadOption) *store.Iterator {
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
 	kr := store.NewIterator(ctx)
 
 	go func() {
-		err := s.db.View(func(txn *badger.Txn) error {
+		err := r.view(func(txn *badger.Txn) error {
 			for _, key := range keys {
 				item, err := txn.Get(key)
+				if err == badger.ErrKeyNotFound && readOptions.AllowMissing {
+					if !kr.PushItem(store.KV{Key: key}) {
+						break
+					}
+					continue
+				}
 				if err != nil {
 					return wrapNotFoundError(err)
 				}
@@ -175,87 +295,67 @@ func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
 	return kr
 }
 
-//
-//func (s *Store) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...store.ReadOption) *store.Iterator {
-//	sit := store.NewIterator(ctx)
-//	log.Debugw("scanning", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd), "limit", store.Limit(limit))
-//	go func() {
-//		err := s.db.View(func(txn *badger.Txn) error {
-//			badgerOptions := badgerIteratorOptions(store.Limit(limit), options)
-//			bit := txn.NewIterator(badgerOptions)
-//			defer bit.Close()
-//
-//			var err error
-//			count := uint64(0)
-//			for bit.Seek(start); bit.Valid() && bytes.Compare(bit.Item().Key(), exclusiveEnd) == -1; bit.Next() {
-//				count++
-//
-//				// We require value only when `PrefetchValues` is true, otherwise, we are performing a key-only iteration and as such,
-//				// we should not fetch nor decompress actual value
-//				var value []byte
-//				if badgerOptions.PrefetchValues {
-//					value, err = bit.Item().ValueCopy(nil)
-//					if err != nil {
-//						return err
-//					}
-//				}
-//
-//				if !sit.PushItem(store.KV{Key: bit.Item().KeyCopy(nil), Value: value}) {
-//					break
-//				}
-//
-//				if store.Limit(limit).Reached(count) {
-//					break
-//				}
-//			}
-//			return nil
-//		})
-//		if err != nil {
-//			sit.PushError(err)
-//			return
-//		}
-//
-//		sit.PushFinished()
-//	}()
-//
-//	return sit
-//}
-
-func (s *Store) Prefix(ctx context.Context, prefix []byte, limit int, options ...store.ReadOption) *store.Iterator {
-	kr := store.NewIterator(ctx)
+func (r reader) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...store.ReadOption) *store.Iterator {
+	log.Debugw("scanning", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd), "limit", store.Limit(limit))
+
+	return r.rangeIterator(ctx, nil, start, exclusiveEnd, store.Limit(limit), options)
+}
+
+func (r reader) Prefix(ctx context.Context, prefix []byte, limit int, options ...store.ReadOption) *store.Iterator {
 	log.Debugw("prefix scanning", "prefix", store.Key(prefix), "limit", store.Limit(limit))
-	go func() {
-		err := s.db.View(func(txn *badger.Txn) error {
-			badgerOptions := badgerIteratorOptions(store.Limit(limit), options)
-			badgerOptions.Prefix = prefix
 
-			it := txn.NewIterator(badgerOptions)
-			defer it.Close()
+	return r.rangeIterator(ctx, prefix, prefix, store.PrefixEnd(prefix), store.Limit(limit), options)
+}
+
+// ForEachPrefix hands out the keys and values straight from the badger iterator, without copying them.
+func (r reader) ForEachPrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error, options ...store.ReadOption) (err error) {
+	log.Debugw("prefix iterating", "prefix", store.Key(prefix))
+
+	return r.view(func(txn *badger.Txn) error {
+		badgerOptions := badgerIteratorOptions(store.Limit(0), options)
+
+		return iterateRange(txn, badgerOptions, prefix, prefix, store.PrefixEnd(prefix), func(item *badger.Item) (bool, error) {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+
+			if !badgerOptions.PrefetchValues {
+				return true, fn(item.Key(), nil)
+			}
+			return true, item.Value(func(value []byte) error {
+				return fn(item.Key(), value)
+			})
+		})
+	})
+}
+
+func (r reader) rangeIterator(ctx context.Context, prefix, start, exclusiveEnd []byte, limit store.Limit, options []store.ReadOption) *store.Iterator {
+	kr := store.NewIterator(ctx)
+	go func() {
+		err := r.view(func(txn *badger.Txn) error {
+			badgerOptions := badgerIteratorOptions(limit, options)
 
-			var err error
 			count := uint64(0)
-			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			return iterateRange(txn, badgerOptions, prefix, start, exclusiveEnd, func(item *badger.Item) (bool, error) {
 				count++
 
 				// We require value only when `PrefetchValues` is true, otherwise, we are performing a key-only iteration and as such,
 				// we should not fetch nor decompress actual value
 				var value []byte
 				if badgerOptions.PrefetchValues {
-					value, err = it.Item().ValueCopy(nil)
+					var err error
+					value, err = item.ValueCopy(nil)
 					if err != nil {
-						return err
+						return false, err
 					}
 				}
 
-				if !kr.PushItem(store.KV{Key: it.Item().KeyCopy(nil), Value: value}) {
-					break
+				if !kr.PushItem(store.KV{Key: item.KeyCopy(nil), Value: value}) {
+					return false, nil
 				}
 
-				if store.Limit(limit).Reached(count) {
-					break
-				}
-			}
-			return nil
+				return !limit.Reached(count), nil
+			})
 		})
 		if err != nil {
 			kr.PushError(err)
@@ -268,6 +368,75 @@ func (s *Store) Prefix(ctx context.Context, prefix []byte, limit int, options ..
 	return kr
 }
 
+// iterateRange calls fn on every item of [start, exclusiveEnd), in descending order when `opts.Reverse` is set,
+// until fn returns false or an error. A non-empty prefix narrows the tables visited by forward iterations.
+func iterateRange(txn *badger.Txn, opts badger.IteratorOptions, prefix, start, exclusiveEnd []byte, fn func(item *badger.Item) (bool, error)) error {
+	if !opts.Reverse {
+		// A reverse iteration seeks from `exclusiveEnd`, which is out of the prefix, so it cannot be narrowed that way
+		opts.Prefix = prefix
+	}
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	inRange := func(key []byte) bool {
+		return store.BeforeEnd(key, exclusiveEnd)
+	}
+
+	if opts.Reverse {
+		inRange = func(key []byte) bool {
+			return bytes.Compare(key, start) >= 0
+		}
+
+		// Seeking backward lands on the last key lower or equal to `exclusiveEnd`, which must be skipped
+		if len(exclusiveEnd) == 0 {
+			it.Rewind()
+		} else if it.Seek(exclusiveEnd); it.Valid() && !store.BeforeEnd(it.Item().Key(), exclusiveEnd) {
+			it.Next()
+		}
+	} else {
+		it.Seek(start)
+	}
+
+	for ; it.Valid() && inRange(it.Item().Key()); it.Next() {
+		next, err := fn(it.Item())
+		if err != nil {
+			return err
+		}
+		if !next {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *Store) PrefixPage(ctx context.Context, prefix []byte, pageSize int, token string, options ...store.ReadOption) (items []store.KV, next string, err error) {
+	log.Debugw("prefix paging", "prefix", store.Key(prefix), "page_size", pageSize)
+
+	return store.ScanPage(ctx, s, prefix, pageSize, token, options...)
+}
+
+func (r reader) Count(ctx context.Context, prefix []byte) (count int64, err error) {
+	log.Debugw("counting", "prefix", store.Key(prefix))
+	err = r.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return
+}
+
 func badgerIteratorOptions(limit store.Limit, options []store.ReadOption) badger.IteratorOptions {
 	if limit.Unbounded() && len(options) == 0 {
 		return badger.DefaultIteratorOptions
@@ -279,6 +448,7 @@ func badgerIteratorOptions(limit store.Limit, options []store.ReadOption) badger
 	}
 
 	opts := badger.DefaultIteratorOptions
+	opts.Reverse = readOptions.Reverse
 	if readOptions.KeyOnly {
 		opts.PrefetchValues = false
 	} else if limit.Bounded() && int(limit) < opts.PrefetchSize {
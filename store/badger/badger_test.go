@@ -2,10 +2,16 @@ package badger
 
 import (
 	"context"
+	"errors"
 	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 func makeStore(t *testing.T) store.Store {
@@ -89,3 +95,708 @@ func TestStore(t *testing.T) {
 
 	require.NoError(t, st.Close())
 }
+
+func TestStore_Scan(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("height/01"), []byte("height/02"), []byte("height/03"), []byte("height/04")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	collect := func(it *store.Iterator) (out [][]byte) {
+		for it.Next() {
+			out = append(out, it.Item().Key)
+		}
+		require.NoError(t, it.Err())
+		return out
+	}
+
+	require.Equal(t, keys[1:3], collect(st.Scan(ctx, keys[1], keys[3], 0)))
+	require.Equal(t, keys[1:2], collect(st.Scan(ctx, keys[1], keys[3], 1)))
+	require.Equal(t, keys[2:], collect(st.Scan(ctx, keys[2], nil, 0)))
+	require.Empty(t, collect(st.Scan(ctx, keys[3], keys[3], 0)))
+
+	it := st.Scan(ctx, keys[0], keys[2], 0, store.KeyOnly())
+	for it.Next() {
+		require.Nil(t, it.Item().Value)
+	}
+	require.NoError(t, it.Err())
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Reverse(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("a"), []byte("b/1"), []byte("b/2"), []byte("b/3"), []byte("c")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	collect := func(it *store.Iterator) (out [][]byte) {
+		for it.Next() {
+			out = append(out, it.Item().Key)
+		}
+		require.NoError(t, it.Err())
+		return out
+	}
+
+	require.Equal(t, [][]byte{keys[3], keys[2], keys[1]}, collect(st.Prefix(ctx, []byte("b/"), 0, store.Reverse())))
+	require.Equal(t, [][]byte{keys[3], keys[2]}, collect(st.Prefix(ctx, []byte("b/"), 2, store.Reverse())))
+	require.Equal(t, [][]byte{keys[3], keys[2], keys[1]}, collect(st.Scan(ctx, keys[1], keys[4], 0, store.Reverse())))
+	require.Equal(t, [][]byte{keys[4], keys[3]}, collect(st.Scan(ctx, keys[3], nil, 0, store.Reverse(), store.KeyOnly())))
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Cursor(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	c, err := st.(store.Cursorer).NewCursor(ctx)
+	require.NoError(t, err)
+
+	require.True(t, c.Next())
+	require.Equal(t, keys[0], c.Key())
+	require.False(t, c.Prev())
+
+	require.True(t, c.Seek([]byte("bb")))
+	require.Equal(t, keys[2], c.Key())
+	require.Equal(t, keys[2], c.Value())
+	require.True(t, c.Prev())
+	require.Equal(t, keys[1], c.Key())
+	require.True(t, c.Next())
+	require.Equal(t, keys[2], c.Key())
+	require.True(t, c.Next())
+	require.Equal(t, keys[3], c.Key())
+	require.False(t, c.Next())
+
+	require.True(t, c.Prev())
+	require.Equal(t, keys[3], c.Key())
+	require.False(t, c.Seek([]byte("e")))
+	require.NoError(t, c.Err())
+	require.NoError(t, c.Close())
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Txn(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	tst := st.(store.Transactional)
+
+	key, index := []byte("payload"), []byte("index")
+
+	txn1, err := tst.Begin(ctx)
+	require.NoError(t, err)
+	txn2, err := tst.Begin(ctx)
+	require.NoError(t, err)
+
+	_, err = txn1.Get(ctx, key)
+	require.ErrorIs(t, err, store.ErrNotFound)
+	require.NoError(t, txn1.Put(ctx, key, []byte("v1")))
+	require.NoError(t, txn1.Put(ctx, index, key))
+
+	v, err := txn1.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	_, err = txn2.Get(ctx, key)
+	require.ErrorIs(t, err, store.ErrNotFound)
+	require.NoError(t, txn2.Put(ctx, key, []byte("v2")))
+
+	require.NoError(t, txn1.Commit(ctx))
+	require.ErrorIs(t, txn2.Commit(ctx), store.ErrConflict)
+
+	attempts := 0
+	err = store.RunTxn(ctx, tst, func(txn store.Txn) error {
+		attempts++
+		v, err := txn.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// concurrent modification forcing a retry
+			require.NoError(t, st.Put(ctx, key, []byte("v3")))
+			require.NoError(t, st.FlushPuts(ctx))
+		}
+		return txn.Put(ctx, key, append(v, '!'))
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	v, err = st.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v3!"), v)
+
+	v, err = st.Get(ctx, index)
+	require.NoError(t, err)
+	require.Equal(t, key, v)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Versioned(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	vst := st.(store.Versioned)
+
+	key := []byte("voucher")
+
+	require.NoError(t, vst.PutIfAbsent(ctx, key, []byte("v1")))
+	require.ErrorIs(t, vst.PutIfAbsent(ctx, key, []byte("v1")), store.ErrConflict)
+
+	v, version, err := vst.GetVersioned(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	require.NoError(t, vst.CompareAndSwap(ctx, key, version, []byte("v2")))
+	require.ErrorIs(t, vst.CompareAndSwap(ctx, key, version, []byte("v3")), store.ErrConflict)
+
+	v, err = st.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), v)
+
+	require.NoError(t, st.Delete(ctx, key))
+	require.ErrorIs(t, vst.CompareAndSwap(ctx, key, version, []byte("v3")), store.ErrConflict)
+	_, _, err = vst.GetVersioned(ctx, key)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Update(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	key := []byte("counter")
+
+	incr := func(old []byte, exists bool) ([]byte, error) {
+		n := 0
+		if exists {
+			n, _ = strconv.Atoi(string(old))
+		}
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, store.Update(ctx, st.(store.Versioned), key, incr))
+			}
+		}()
+	}
+	wg.Wait()
+
+	v, err := st.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("40"), v)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_TTL(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	require.NoError(t, st.Put(ctx, []byte("cache"), []byte("value"), store.WithTTL(time.Second)))
+	require.NoError(t, st.Put(ctx, []byte("durable"), []byte("value")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	_, err := st.Get(ctx, []byte("cache"))
+	require.NoError(t, err)
+
+	time.Sleep(2 * time.Second)
+
+	_, err = st.Get(ctx, []byte("cache"))
+	require.ErrorIs(t, err, store.ErrNotFound)
+	_, err = st.Get(ctx, []byte("durable"))
+	require.NoError(t, err)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Watch(t *testing.T) {
+	st := makeStore(t)
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	events := st.(store.Watcher).Watch(ctx, []byte("config/"))
+
+	// The subscription registers in the background, so the put is repeated until it is streamed
+	var ev store.Event
+	for streamed := false; !streamed; {
+		require.NoError(t, st.Put(ctx, []byte("config/a"), []byte("1")))
+		require.NoError(t, st.Put(ctx, []byte("other"), []byte("1")))
+		require.NoError(t, st.FlushPuts(ctx))
+		select {
+		case ev = <-events:
+			streamed = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	require.Equal(t, store.EventPut, ev.Type)
+	require.Equal(t, []byte("config/a"), ev.Key)
+	require.Equal(t, []byte("1"), ev.Value)
+
+	// Puts of the previous attempts may still be streamed before the deletion
+	require.NoError(t, st.Delete(ctx, []byte("config/a")))
+	for ev = range events {
+		if ev.Type == store.EventDelete {
+			break
+		}
+		require.Equal(t, []byte("config/a"), ev.Key)
+	}
+	require.Equal(t, store.EventDelete, ev.Type)
+	require.Equal(t, []byte("config/a"), ev.Key)
+	require.Nil(t, ev.Value)
+
+	cancel()
+	for range events {
+	}
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Incr(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	counter := st.(store.Counter)
+	key := []byte("stats/blocks")
+
+	// Contention does not fail increments
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := counter.Incr(ctx, key, 2)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := counter.Incr(ctx, key, -590)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), n)
+
+	v, err := st.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("50"), v)
+
+	require.NoError(t, st.Put(ctx, key, []byte("not a number")))
+	require.NoError(t, st.FlushPuts(ctx))
+	_, err = counter.Incr(ctx, key, 1)
+	require.Error(t, err)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Count(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	for _, k := range []string{"a/1", "a/2", "a/3", "b/1"} {
+		require.NoError(t, st.Put(ctx, []byte(k), []byte(k)))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	n, err := st.Count(ctx, []byte("a/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	n, err = st.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+
+	n, err = st.Count(ctx, []byte("c/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = st.Count(cancelled, nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Has(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	require.NoError(t, st.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, st.Put(ctx, []byte("c"), []byte("3")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	ok, err := st.Has(ctx, []byte("a"))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = st.Has(ctx, []byte("b"))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	exists, err := st.BatchHas(ctx, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false, true}, exists)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_BatchGetAllowMissing(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	require.NoError(t, st.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, st.Put(ctx, []byte("c"), []byte("3")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	it := st.BatchGet(ctx, keys)
+	require.True(t, it.Next())
+	require.False(t, it.Next())
+	require.ErrorIs(t, it.Err(), store.ErrNotFound)
+
+	it = st.BatchGet(ctx, keys, store.AllowMissing())
+	var kvs []store.KV
+	for it.Next() {
+		kvs = append(kvs, it.Item())
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []store.KV{
+		{Key: keys[0], Value: []byte("1")},
+		{Key: keys[1]},
+		{Key: keys[2], Value: []byte("3")},
+	}, kvs)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_PrefixPage(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("p/1"), []byte("p/2"), []byte("p/3"), []byte("p/4"), []byte("p/5")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.Put(ctx, []byte("q/1"), []byte("q")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	readAll := func(options ...store.ReadOption) (pages [][][]byte) {
+		token := ""
+		for {
+			items, next, err := st.PrefixPage(ctx, []byte("p/"), 2, token, options...)
+			require.NoError(t, err)
+
+			var page [][]byte
+			for _, item := range items {
+				page = append(page, item.Key)
+			}
+			pages = append(pages, page)
+
+			if next == "" {
+				return pages
+			}
+			token = next
+		}
+	}
+
+	require.Equal(t, [][][]byte{keys[0:2], keys[2:4], keys[4:]}, readAll())
+	require.Equal(t, [][][]byte{{keys[4], keys[3]}, {keys[2], keys[1]}, {keys[0]}}, readAll(store.Reverse()))
+
+	_, _, err := st.PrefixPage(ctx, []byte("p/"), 2, "71", store.KeyOnly())
+	require.Error(t, err)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	require.NoError(t, st.Put(ctx, []byte("report/a"), []byte("1")))
+	require.NoError(t, st.Put(ctx, []byte("report/b"), []byte("1")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	sn, err := st.(store.Snapshotter).Snapshot(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, st.Put(ctx, []byte("report/a"), []byte("2")))
+	require.NoError(t, st.Put(ctx, []byte("report/c"), []byte("2")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	v, err := sn.Get(ctx, []byte("report/a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+
+	n, err := sn.Count(ctx, []byte("report/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	it := sn.Prefix(ctx, []byte("report/"), 0)
+	var vv [][]byte
+	for it.Next() {
+		vv = append(vv, it.Item().Value)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, [][]byte{[]byte("1"), []byte("1")}, vv)
+
+	require.NoError(t, sn.Close())
+	_, err = sn.Get(ctx, []byte("report/a"))
+	require.Error(t, err)
+
+	v, err = st.Get(ctx, []byte("report/a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), v)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_History(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger")
+	require.NoError(t, err)
+	st, err := NewStore("badger://" + dir + "?versions=5")
+	require.NoError(t, err)
+	ctx := context.TODO()
+	key := []byte("miner/state")
+
+	for _, v := range []string{"1", "2", "3"} {
+		require.NoError(t, st.Put(ctx, key, []byte(v)))
+		require.NoError(t, st.FlushPuts(ctx))
+	}
+
+	history, err := st.(store.HistoryReader).History(ctx, key, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, []byte("3"), history[0].Value)
+	require.Equal(t, []byte("1"), history[2].Value)
+
+	history, err = st.(store.HistoryReader).History(ctx, key, 2)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	_, version, err := st.(store.Versioned).GetVersioned(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, version, history[0].Version)
+
+	_, err = st.(store.HistoryReader).History(ctx, []byte("missing"), 0)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	// A deletion ends the history
+	require.NoError(t, st.Delete(ctx, key))
+	_, err = st.(store.HistoryReader).History(ctx, key, 0)
+	require.ErrorIs(t, err, store.ErrNotFound)
+	require.NoError(t, st.Put(ctx, key, []byte("4")))
+	require.NoError(t, st.FlushPuts(ctx))
+	history, err = st.(store.HistoryReader).History(ctx, key, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, []byte("4"), history[0].Value)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_DeletePrefixAndRange(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	for _, k := range []string{"a/1", "a/2", "b/1", "b/2", "b/3", "c/1"} {
+		require.NoError(t, st.Put(ctx, []byte(k), []byte(k)))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	require.NoError(t, st.DeletePrefix(ctx, []byte("a/")))
+	n, err := st.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+
+	require.NoError(t, st.DeleteRange(ctx, []byte("b/2"), []byte("c/1")))
+	exists, err := st.BatchHas(ctx, [][]byte{[]byte("b/1"), []byte("b/2"), []byte("b/3"), []byte("c/1")})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false, false, true}, exists)
+
+	require.NoError(t, st.DeleteRange(ctx, []byte("b/"), nil))
+	n, err = st.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_ForEachPrefix(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("a"), []byte("b/1"), []byte("b/2"), []byte("b/3"), []byte("c")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, append([]byte("v-"), k...)))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	var seen []string
+	require.NoError(t, st.ForEachPrefix(ctx, []byte("b/"), func(key, value []byte) error {
+		require.Equal(t, "v-"+string(key), string(value))
+		seen = append(seen, string(key))
+		return nil
+	}))
+	require.Equal(t, []string{"b/1", "b/2", "b/3"}, seen)
+
+	seen = nil
+	require.NoError(t, st.ForEachPrefix(ctx, []byte("b/"), func(key, value []byte) error {
+		require.Nil(t, value)
+		seen = append(seen, string(key))
+		return nil
+	}, store.Reverse(), store.KeyOnly()))
+	require.Equal(t, []string{"b/3", "b/2", "b/1"}, seen)
+
+	stop := errors.New("stop")
+	seen = nil
+	err := st.ForEachPrefix(ctx, nil, func(key, value []byte) error {
+		seen = append(seen, string(key))
+		return stop
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, []string{"a"}, seen)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_Sync(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	syncs := 0
+	syncDB = func(db *badger.DB) error {
+		syncs++
+		return db.Sync()
+	}
+	defer func() { syncDB = (*badger.DB).Sync }()
+
+	// Only the flushes holding a write which asked for it sync
+	require.NoError(t, st.Put(ctx, []byte("voucher"), []byte("pending")))
+	require.False(t, st.(*Store).writeBatch.sync)
+	require.NoError(t, st.FlushPuts(ctx))
+	require.Equal(t, 0, syncs)
+
+	require.NoError(t, st.Put(ctx, []byte("voucher"), []byte("paid"), store.Sync()))
+	require.True(t, st.(*Store).writeBatch.sync)
+	require.NoError(t, st.FlushPuts(ctx))
+	require.Equal(t, 1, syncs)
+	require.False(t, st.(*Store).writeBatch.sync)
+
+	b := st.NewBatch()
+	require.NoError(t, b.Put(ctx, []byte("receipt"), []byte("1"), store.Sync()))
+	require.NoError(t, b.Commit(ctx))
+	require.Equal(t, 2, syncs)
+
+	value, err := st.Get(ctx, []byte("voucher"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("paid"), value)
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_NewBatch(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			b := st.NewBatch()
+			for i := 0; i < 100; i++ {
+				assert.NoError(t, b.Put(ctx, []byte("batch/"+strconv.Itoa(w)+"/"+strconv.Itoa(i)), []byte("v")))
+			}
+			assert.Equal(t, 100, b.Len())
+			assert.NoError(t, b.Commit(ctx))
+			assert.Equal(t, 0, b.Len())
+			b.Discard()
+		}(w)
+	}
+	wg.Wait()
+
+	n, err := st.Count(ctx, []byte("batch/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(400), n)
+
+	b := st.NewBatch()
+	require.NoError(t, b.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, b.Delete(ctx, []byte("batch/0/0")))
+	require.Equal(t, 2, b.Len())
+	require.Equal(t, 2+len("batch/0/0"), b.Size())
+	b.Discard()
+	exists, err := st.BatchHas(ctx, [][]byte{[]byte("a"), []byte("batch/0/0")})
+	require.NoError(t, err)
+	require.Equal(t, []bool{false, true}, exists)
+
+	// Wrappers write through batches of the underlying store
+	chunked := store.WithChunking(store.WithNamespace(st, "ns/"), 4)
+	b = chunked.NewBatch()
+	require.NoError(t, b.Put(ctx, []byte("big"), []byte("0123456789")))
+	require.NoError(t, b.Put(ctx, []byte("big"), []byte("01234")))
+	require.NoError(t, b.Commit(ctx))
+	value, err := chunked.Get(ctx, []byte("big"))
+	require.NoError(t, err)
+	require.Equal(t, "01234", string(value))
+	n, err = st.Count(ctx, []byte("ns/\xff\xffchunk/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	require.NoError(t, b.Delete(ctx, []byte("big")))
+	require.NoError(t, b.Commit(ctx))
+	n, err = st.Count(ctx, []byte("ns/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+
+	// A failed commit keeps the writes
+	b = st.NewBatch()
+	require.NoError(t, b.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, b.Put(ctx, nil, []byte("empty key")))
+	require.Error(t, b.Commit(ctx))
+	require.Equal(t, 2, b.Len())
+
+	require.NoError(t, st.Close())
+}
+
+func TestStore_BatchPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger")
+	require.NoError(t, err)
+	st, err := NewStore("badger://" + dir + "?batch_size=2&flush_interval=50ms")
+	require.NoError(t, err)
+	ctx := context.TODO()
+
+	// batch_size flushes the second put right away
+	require.NoError(t, st.Put(ctx, []byte("a"), []byte("1")))
+	require.NoError(t, st.Put(ctx, []byte("b"), []byte("2")))
+	value, err := st.Get(ctx, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+
+	// flush_interval flushes the third one in the background
+	require.NoError(t, st.Put(ctx, []byte("c"), []byte("3")))
+	require.Eventually(t, func() bool {
+		_, err := st.Get(ctx, []byte("c"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, st.Close())
+}
@@ -0,0 +1,101 @@
+package badger
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+)
+
+// NewBatch returns a batch holding its writes until Commit, which applies them with a badger
+// WriteBatch.  A WriteBatch commits by itself the writes exceeding the size of a badger transaction, so
+// a large batch is not applied atomically.
+func (s *Store) NewBatch() store.Batch {
+	return s.newBatch()
+}
+
+func (s *Store) newBatch() *batch {
+	return &batch{db: s.db}
+}
+
+// syncDB syncs the database to disk, replaced by tests.
+var syncDB = (*badger.DB).Sync
+
+type batch struct {
+	db *badger.DB
+
+	writes []pendingWrite
+	size   int
+	// sync tells whether a write asked for `Sync()`
+	sync bool
+}
+
+// pendingWrite is kept rather than a badger Entry, which is modified when committed.
+type pendingWrite struct {
+	key, value   []byte
+	writeOptions store.WriteOptions
+	deleted      bool
+}
+
+func (b *batch) Put(_ context.Context, key, value []byte, options ...store.WriteOption) (err error) {
+	writeOptions := store.WriteOptions{}
+	for _, opt := range options {
+		opt.Apply(&writeOptions)
+	}
+
+	b.writes = append(b.writes, pendingWrite{key: key, value: value, writeOptions: writeOptions})
+	b.size += len(key) + len(value)
+	b.sync = b.sync || writeOptions.Sync
+	return nil
+}
+
+func (b *batch) Delete(_ context.Context, key []byte) (err error) {
+	b.writes = append(b.writes, pendingWrite{key: key, deleted: true})
+	b.size += len(key)
+	return nil
+}
+
+func (b *batch) Len() int {
+	return len(b.writes)
+}
+
+func (b *batch) Size() int {
+	return b.size
+}
+
+// Commit keeps the writes when it fails, so that it can be retried.
+func (b *batch) Commit(_ context.Context) (err error) {
+	if len(b.writes) == 0 {
+		return nil
+	}
+
+	wb := b.db.NewWriteBatch()
+	for _, w := range b.writes {
+		if w.deleted {
+			err = wb.Delete(w.key)
+		} else {
+			err = wb.SetEntry(newEntry(w.key, w.value, w.writeOptions))
+		}
+		if err != nil {
+			wb.Cancel()
+			return fmt.Errorf("write entry: %w", err)
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+
+	// The value log is written without syncing unless the database syncs every write
+	if b.sync {
+		if err := syncDB(b.db); err != nil {
+			return fmt.Errorf("sync: %w", err)
+		}
+	}
+
+	b.writes, b.size, b.sync = nil, 0, false
+	return nil
+}
+
+func (b *batch) Discard() {
+	b.writes, b.size, b.sync = nil, 0, false
+}
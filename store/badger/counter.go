@@ -0,0 +1,39 @@
+package badger
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+	"strconv"
+)
+
+var _ store.Counter = (*Store)(nil)
+
+// Incr reads and writes the counter in a transaction, retried until ctx is done when another writer
+// modified the counter.
+func (s *Store) Incr(ctx context.Context, key []byte, delta int64) (value int64, err error) {
+	log.Debugw("incrementing", "key", store.Key(key), "delta", delta)
+	err = store.RetryOnConflict(ctx, func() error {
+		err := s.db.Update(func(txn *badger.Txn) error {
+			value = 0
+			item, err := txn.Get(key)
+			if err != nil && err != badger.ErrKeyNotFound {
+				return err
+			}
+			if err == nil {
+				err = item.Value(func(old []byte) (err error) {
+					value, err = store.ParseCounter(old)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			value += delta
+			return txn.Set(key, []byte(strconv.FormatInt(value, 10)))
+		})
+		return wrapConflictError(err)
+	})
+	return value, err
+}
@@ -0,0 +1,145 @@
+package badger
+
+import (
+	"bytes"
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+)
+
+var _ store.Cursorer = (*Store)(nil)
+
+// NewCursor opens a cursor reading from a read-only transaction: it sees the state of the database at
+// the time it was opened, and holds on that snapshot until closed.
+func (s *Store) NewCursor(ctx context.Context, options ...store.ReadOption) (store.Cursor, error) {
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
+	return &cursor{
+		ctx:     ctx,
+		txn:     s.db.NewTransaction(false),
+		keyOnly: readOptions.KeyOnly,
+	}, nil
+}
+
+// cursor moves a badger iterator, which can only go in one direction, and replaces it by one going the
+// other way when the direction changes.
+type cursor struct {
+	ctx     context.Context
+	txn     *badger.Txn
+	it      *badger.Iterator
+	reverse bool
+	keyOnly bool
+
+	key   []byte
+	value []byte
+	err   error
+}
+
+func (c *cursor) Seek(key []byte) bool {
+	if !c.open(false) {
+		return false
+	}
+
+	c.it.Seek(key)
+	return c.load()
+}
+
+func (c *cursor) Next() bool {
+	return c.move(false)
+}
+
+func (c *cursor) Prev() bool {
+	return c.move(true)
+}
+
+func (c *cursor) move(reverse bool) bool {
+	if c.key == nil || c.it == nil {
+		if !c.open(reverse) {
+			return false
+		}
+		c.it.Rewind()
+		return c.load()
+	}
+
+	if c.reverse != reverse {
+		current := c.key
+		if !c.open(reverse) {
+			return false
+		}
+
+		// Seeking lands back on the current key, which is skipped below
+		c.it.Seek(current)
+		if !c.it.Valid() || !bytes.Equal(c.it.Item().Key(), current) {
+			return c.load()
+		}
+	}
+
+	c.it.Next()
+	return c.load()
+}
+
+// open makes sure an iterator is available going in the given direction.
+func (c *cursor) open(reverse bool) bool {
+	if err := c.ctx.Err(); err != nil {
+		c.err = err
+		return false
+	}
+
+	if c.it != nil && c.reverse == reverse {
+		return true
+	}
+
+	if c.it != nil {
+		c.it.Close()
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = reverse
+	opts.PrefetchValues = !c.keyOnly
+	c.it = c.txn.NewIterator(opts)
+	c.reverse = reverse
+	return true
+}
+
+func (c *cursor) load() bool {
+	c.key, c.value = nil, nil
+	if !c.it.Valid() {
+		return false
+	}
+
+	item := c.it.Item()
+	if !c.keyOnly {
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			c.err = err
+			return false
+		}
+		c.value = value
+	}
+	c.key = item.KeyCopy(nil)
+	return true
+}
+
+func (c *cursor) Key() []byte {
+	return c.key
+}
+
+func (c *cursor) Value() []byte {
+	return c.value
+}
+
+func (c *cursor) Err() error {
+	return c.err
+}
+
+func (c *cursor) Close() error {
+	if c.it != nil {
+		c.it.Close()
+		c.it = nil
+	}
+	c.txn.Discard()
+	return nil
+}
@@ -2,15 +2,20 @@ package badger
 
 import (
 	"fmt"
+	"github.com/bitrainforest/kdb/store"
 	"github.com/dgraph-io/badger/v3"
 	"github.com/dgraph-io/badger/v3/options"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
 type dsn struct {
 	dbPath      string
 	compression options.CompressionType // none, snappy, zstd
+	versions    int                     // number of versions to keep per key, badger default when 0
+	syncWrites  bool                    // sync every write to disk
+	batch       store.BatchPolicy       // when to flush the pending writes
 }
 
 func newDSN(dsnString string) (*dsn, error) {
@@ -42,10 +47,35 @@ func newDSN(dsnString string) (*dsn, error) {
 	default:
 		return nil, fmt.Errorf("badger: invalid compression type %q", u.Query().Get("compression"))
 	}
+
+	if u.Query().Has("versions") {
+		versions := u.Query().Get("versions")
+		i, err := strconv.Atoi(versions)
+		if err != nil || i < 1 {
+			return nil, fmt.Errorf("badger: invalid versions %q", versions)
+		}
+		r.versions = i
+	}
+
+	r.batch, err = store.ParseBatchPolicy(u.Query(), defaultBatchPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("badger: %w", err)
+	}
+
+	if u.Query().Has("sync") {
+		syncWrites, err := strconv.ParseBool(u.Query().Get("sync"))
+		if err != nil {
+			return nil, fmt.Errorf("badger: invalid sync %q", u.Query().Get("sync"))
+		}
+		r.syncWrites = syncWrites
+	}
 	return r, nil
 }
 
 func dsnToOptions(d *dsn) badger.Options {
-	opts := badger.DefaultOptions(d.dbPath).WithLogger(nil).WithCompression(d.compression)
+	opts := badger.DefaultOptions(d.dbPath).WithLogger(nil).WithCompression(d.compression).WithSyncWrites(d.syncWrites)
+	if d.versions > 0 {
+		opts = opts.WithNumVersionsToKeep(d.versions)
+	}
 	return opts
 }
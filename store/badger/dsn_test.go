@@ -1,10 +1,12 @@
 package badger
 
 import (
+	"github.com/bitrainforest/kdb/store"
 	"github.com/dgraph-io/badger/v3/options"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func Test_parseDNS(t *testing.T) {
@@ -21,6 +23,7 @@ func Test_parseDNS(t *testing.T) {
 			expectDSN: &dsn{
 				dbPath:      "badger-db.db",
 				compression: options.Snappy,
+				batch:       defaultBatchPolicy,
 			},
 		},
 		{
@@ -30,6 +33,7 @@ func Test_parseDNS(t *testing.T) {
 			expectDSN: &dsn{
 				dbPath:      "/Users/john/kdb/badger-db.db",
 				compression: options.ZSTD,
+				batch:       defaultBatchPolicy,
 			},
 		},
 		{
@@ -39,8 +43,65 @@ func Test_parseDNS(t *testing.T) {
 			expectDSN: &dsn{
 				dbPath:      "/Users/john/kdb/badger-db.db",
 				compression: options.None,
+				batch:       defaultBatchPolicy,
 			},
 		},
+		{
+			name:        "versions",
+			dns:         "badger:///Users/john/kdb/badger-db.db?versions=10",
+			expectError: false,
+			expectDSN: &dsn{
+				dbPath:      "/Users/john/kdb/badger-db.db",
+				compression: options.None,
+				versions:    10,
+				batch:       defaultBatchPolicy,
+			},
+		},
+		{
+			name:        "invalid versions",
+			dns:         "badger:///Users/john/kdb/badger-db.db?versions=0",
+			expectError: true,
+		},
+		{
+			name:        "sync",
+			dns:         "badger:///Users/john/kdb/badger-db.db?sync=true",
+			expectError: false,
+			expectDSN: &dsn{
+				dbPath:      "/Users/john/kdb/badger-db.db",
+				compression: options.None,
+				syncWrites:  true,
+				batch:       defaultBatchPolicy,
+			},
+		},
+		{
+			name:        "invalid sync",
+			dns:         "badger:///Users/john/kdb/badger-db.db?sync=maybe",
+			expectError: true,
+		},
+		{
+			name:        "batching",
+			dns:         "badger:///Users/john/kdb/badger-db.db?batch_size=100&batch_bytes=1048576&flush_interval=1s",
+			expectError: false,
+			expectDSN: &dsn{
+				dbPath:      "/Users/john/kdb/badger-db.db",
+				compression: options.None,
+				batch:       store.BatchPolicy{Size: 100, Bytes: 1 << 20, FlushInterval: time.Second},
+			},
+		},
+		{
+			name:        "unlimited batch",
+			dns:         "badger:///Users/john/kdb/badger-db.db?batch_size=0&batch_bytes=0",
+			expectError: false,
+			expectDSN: &dsn{
+				dbPath:      "/Users/john/kdb/badger-db.db",
+				compression: options.None,
+			},
+		},
+		{
+			name:        "invalid flush interval",
+			dns:         "badger:///Users/john/kdb/badger-db.db?flush_interval=soon",
+			expectError: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,50 @@
+package badger
+
+import (
+	"bytes"
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+)
+
+var _ store.HistoryReader = (*Store)(nil)
+
+// History returns the versions badger still holds, as many as the `versions` DSN parameter allows (and
+// possibly more until they are garbage collected). Like a deletion, an expiration ends the history: the
+// versions older than it are not reported.
+func (s *Store) History(_ context.Context, key []byte, limit int) (history []store.VersionedKV, err error) {
+	log.Debugw("history", "key", store.Key(key), "limit", store.Limit(limit))
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.AllVersions = true
+		opts.Prefix = key
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(key); it.Valid() && bytes.Equal(it.Item().Key(), key); it.Next() {
+			item := it.Item()
+			if item.IsDeletedOrExpired() {
+				break
+			}
+
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			history = append(history, store.VersionedKV{
+				KV:      store.KV{Key: item.KeyCopy(nil), Value: value},
+				Version: encodeVersion(item.Version()),
+			})
+			if store.Limit(limit).Reached(uint64(len(history))) {
+				break
+			}
+		}
+		return nil
+	})
+	if err == nil && len(history) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return
+}
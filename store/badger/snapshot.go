@@ -0,0 +1,47 @@
+package badger
+
+import (
+	"context"
+	"errors"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+	"sync"
+)
+
+var _ store.Snapshotter = (*Store)(nil)
+
+var errSnapshotClosed = errors.New("snapshot closed")
+
+// Snapshot holds a read-only transaction, which sees the database as of its start.
+func (s *Store) Snapshot(_ context.Context) (store.Snapshot, error) {
+	sn := &snapshot{txn: s.db.NewTransaction(false)}
+	sn.reader = reader{view: sn.view}
+	return sn, nil
+}
+
+type snapshot struct {
+	reader
+	txn    *badger.Txn
+	lk     sync.RWMutex
+	closed bool
+}
+
+func (sn *snapshot) view(fn func(txn *badger.Txn) error) error {
+	sn.lk.RLock()
+	defer sn.lk.RUnlock()
+	if sn.closed {
+		return errSnapshotClosed
+	}
+	return fn(sn.txn)
+}
+
+// Close waits for the reads in progress before discarding the transaction.
+func (sn *snapshot) Close() error {
+	sn.lk.Lock()
+	defer sn.lk.Unlock()
+	if !sn.closed {
+		sn.closed = true
+		sn.txn.Discard()
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package badger
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+)
+
+var _ store.Transactional = (*Store)(nil)
+
+func (s *Store) Begin(_ context.Context) (store.Txn, error) {
+	return &txn{txn: s.db.NewTransaction(true)}, nil
+}
+
+// txn wraps a badger read-write transaction, which detects conflicts on the keys it read at commit time.
+type txn struct {
+	txn *badger.Txn
+}
+
+func (t *txn) Get(_ context.Context, key []byte) (value []byte, err error) {
+	item, err := t.txn.Get(key)
+	if err != nil {
+		return nil, wrapNotFoundError(err)
+	}
+	return item.ValueCopy(nil)
+}
+
+func (t *txn) Put(_ context.Context, key, value []byte) (err error) {
+	return t.txn.Set(key, value)
+}
+
+func (t *txn) Delete(_ context.Context, key []byte) (err error) {
+	return t.txn.Delete(key)
+}
+
+func (t *txn) Commit(_ context.Context) (err error) {
+	return wrapConflictError(t.txn.Commit())
+}
+
+func (t *txn) Rollback() (err error) {
+	t.txn.Discard()
+	return nil
+}
+
+func wrapConflictError(err error) error {
+	if err == badger.ErrConflict {
+		return store.ErrConflict
+	}
+	return err
+}
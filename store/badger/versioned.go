@@ -0,0 +1,69 @@
+package badger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+)
+
+var _ store.Versioned = (*Store)(nil)
+
+// GetVersioned returns the commit timestamp of the key as its version.
+func (s *Store) GetVersioned(_ context.Context, key []byte) (value []byte, version store.Version, err error) {
+	err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return wrapNotFoundError(err)
+		}
+
+		value, err = item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		version = encodeVersion(item.Version())
+		return nil
+	})
+	return
+}
+
+func (s *Store) CompareAndSwap(_ context.Context, key []byte, expected store.Version, value []byte) (err error) {
+	log.Debugw("compare and swap", "key", store.Key(key))
+	err = s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			return store.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(encodeVersion(item.Version()), expected) {
+			return store.ErrConflict
+		}
+		return txn.Set(key, value)
+	})
+	return wrapConflictError(err)
+}
+
+func (s *Store) PutIfAbsent(_ context.Context, key, value []byte) (err error) {
+	log.Debugw("put if absent", "key", store.Key(key))
+	err = s.db.Update(func(txn *badger.Txn) error {
+		_, err := txn.Get(key)
+		if err == nil {
+			return store.ErrConflict
+		}
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+		return txn.Set(key, value)
+	})
+	return wrapConflictError(err)
+}
+
+func encodeVersion(version uint64) store.Version {
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, version)
+	return out
+}
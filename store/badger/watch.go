@@ -0,0 +1,47 @@
+package badger
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/dgraph-io/badger/v3/pb"
+)
+
+var _ store.Watcher = (*Store)(nil)
+
+// Watch subscribes to the writes of the database. Badger does not flag deletions to subscribers, so a
+// change without value is reported as a deletion when the key is not found anymore. Expirations are
+// not reported.
+//
+// Badger registers the subscription in the background: the changes made right after Watch returns
+// may not be streamed, until the subscription is registered.
+func (s *Store) Watch(ctx context.Context, prefix []byte) <-chan store.Event {
+	log.Debugw("watching", "prefix", store.Key(prefix))
+	events := make(chan store.Event, 100)
+
+	go func() {
+		defer close(events)
+		err := s.db.Subscribe(ctx, func(list *badger.KVList) error {
+			for _, kv := range list.Kv {
+				ev := store.Event{Type: store.EventPut, Key: kv.Key, Value: kv.Value, Revision: int64(kv.Version)}
+				if len(kv.Value) == 0 {
+					if _, err := s.Get(ctx, kv.Key); err == store.ErrNotFound {
+						ev.Type, ev.Value = store.EventDelete, nil
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case events <- ev:
+				}
+			}
+			return nil
+		}, []pb.Match{{Prefix: prefix}})
+		if err != nil && err != context.Canceled && err != context.DeadlineExceeded {
+			log.Errorf("watch %s: %s", store.Key(prefix), err)
+		}
+	}()
+
+	return events
+}
@@ -0,0 +1,787 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	// chunkKeyPrefix starts the keys holding the chunks of large values.  Those keys are reserved: reads
+	// through a ChunkedStore skip them.
+	chunkKeyPrefix = []byte("\xff\xffchunk/")
+	chunkKeyEnd    = PrefixEnd(chunkKeyPrefix)
+
+	// chunkManifestMagic starts the manifest stored in place of a chunked value.  Values starting with it
+	// are always chunked, so that they cannot be mistaken for a manifest.
+	chunkManifestMagic = []byte("\x00kdb-chunked\x00")
+
+	errCorruptedChunks = errors.New("corrupted chunks")
+)
+
+// ChunkedStore splits the values larger than a threshold into chunks, for backends limiting the size
+// of values or requests.
+//
+// A chunked value is stored as numbered chunk keys, and a manifest stored under the key of the value
+// which tells the size of the value and its number of chunks. Reads reassemble the chunks, so chunking
+// is transparent to callers, besides the following:
+//
+//   - The keys starting with "\xff\xffchunk/" are reserved for the chunks.
+//   - Writes first check whether the key already holds chunks, to delete the chunks left behind by an
+//     overwrite, which costs a read per written key. `FlushPuts` deletes them once the values
+//     replacing them are written.
+//   - `DeletePrefix` and `DeleteRange` read the values of the deleted keys, to find their chunks.
+//   - A chunked value is not written atomically: a concurrent reader may fail to reassemble it.
+//   - `PrefixPage` counts the chunks of the keys in range against the page size, so pages may be short.
+//
+// The optional interfaces of the wrapped store (`Transactional`, `Watcher`...) are not available
+// through a ChunkedStore.
+type ChunkedStore struct {
+	st        Store
+	chunkSize int
+
+	// pending holds the largest number of chunks which the keys Put since the last flush may hold
+	pending map[string]int
+	// writers counts the writers open on each key, which chunks are not deleted until they are closed
+	writers map[string]int
+	lk      sync.Mutex
+}
+
+var _ Store = (*ChunkedStore)(nil)
+
+// WithChunking returns a Store splitting the values larger than `chunkSize` bytes into chunks.
+func WithChunking(st Store, chunkSize int) *ChunkedStore {
+	if chunkSize <= 0 {
+		panic(fmt.Sprintf("invalid chunk size %d", chunkSize))
+	}
+
+	return &ChunkedStore{
+		st:        st,
+		chunkSize: chunkSize,
+		pending:   make(map[string]int),
+		writers:   make(map[string]int),
+	}
+}
+
+// chunkKey returns the key of the chunk `i` of the value of `key`.  The index has a fixed size, so
+// chunk keys cannot collide between keys.
+func chunkKey(key []byte, i int) []byte {
+	out := make([]byte, len(chunkKeyPrefix)+len(key)+4)
+	n := copy(out, chunkKeyPrefix)
+	n += copy(out[n:], key)
+	binary.BigEndian.PutUint32(out[n:], uint32(i))
+	return out
+}
+
+func chunkKeys(key []byte, from, to int) [][]byte {
+	if to <= from {
+		return nil
+	}
+	keys := make([][]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		keys = append(keys, chunkKey(key, i))
+	}
+	return keys
+}
+
+func isChunkKey(key []byte) bool {
+	return bytes.HasPrefix(key, chunkKeyPrefix)
+}
+
+func encodeManifest(size, count int) []byte {
+	out := make([]byte, len(chunkManifestMagic)+2*binary.MaxVarintLen64)
+	n := copy(out, chunkManifestMagic)
+	n += binary.PutUvarint(out[n:], uint64(size))
+	n += binary.PutUvarint(out[n:], uint64(count))
+	return out[:n]
+}
+
+// decodeManifest tells whether `value` is a manifest, and returns the size and the number of chunks
+// of the value it stands for.
+func decodeManifest(value []byte) (size, count int, ok bool, err error) {
+	if !bytes.HasPrefix(value, chunkManifestMagic) {
+		return 0, 0, false, nil
+	}
+
+	buf := value[len(chunkManifestMagic):]
+	s, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return 0, 0, true, errCorruptedChunks
+	}
+	c, m := binary.Uvarint(buf[n:])
+	if m <= 0 {
+		return 0, 0, true, errCorruptedChunks
+	}
+	return int(s), int(c), true, nil
+}
+
+// needsChunks tells whether a value must be stored as chunks.
+func (c *ChunkedStore) needsChunks(value []byte) bool {
+	return len(value) > c.chunkSize || bytes.HasPrefix(value, chunkManifestMagic)
+}
+
+func (c *ChunkedStore) Put(ctx context.Context, key, value []byte, options ...WriteOption) (err error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	previous, err := c.previous(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var chunks [][]byte
+	if c.needsChunks(value) {
+		chunks = c.split(value)
+		for i, chunk := range chunks {
+			if err := c.st.Put(ctx, chunkKey(key, i), chunk, options...); err != nil {
+				return err
+			}
+		}
+		value = encodeManifest(len(value), len(chunks))
+	}
+
+	if err := c.st.Put(ctx, key, value, options...); err != nil {
+		return err
+	}
+	c.written(key, previous, len(chunks))
+	return nil
+}
+
+// previous returns the number of chunks which the key may hold before a write, either flushed or
+// Put since the last flush.  c.lk must be held.
+func (c *ChunkedStore) previous(ctx context.Context, key []byte) (int, error) {
+	count, err := c.chunkCount(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if pending := c.pending[string(key)]; pending > count {
+		count = pending
+	}
+	return count, nil
+}
+
+// written records that `count` chunks were Put to a key which held up to `previous` chunks, the
+// others being deleted by the next flush.  c.lk must be held.
+func (c *ChunkedStore) written(key []byte, previous, count int) {
+	if count > previous {
+		previous = count
+	}
+	if previous > 0 {
+		c.pending[string(key)] = previous
+	}
+}
+
+// split cuts a value into chunks of `chunkSize` bytes, the last one being shorter.
+func (c *ChunkedStore) split(value []byte) (chunks [][]byte) {
+	for start := 0; start < len(value); start += c.chunkSize {
+		end := start + c.chunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		chunks = append(chunks, value[start:end])
+	}
+	return chunks
+}
+
+// FlushPuts flushes the wrapped store, then deletes the chunks left behind by the values it wrote.
+func (c *ChunkedStore) FlushPuts(ctx context.Context) (err error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if err := c.st.FlushPuts(ctx); err != nil {
+		return err
+	}
+
+	var keys [][]byte
+	for key := range c.pending {
+		if c.writers[key] == 0 {
+			keys = append(keys, []byte(key))
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	// The chunks beyond the ones of the flushed values are stale
+	counts, err := c.chunkCounts(ctx, keys)
+	if err != nil {
+		return err
+	}
+	var stale [][]byte
+	for i, key := range keys {
+		stale = append(stale, chunkKeys(key, counts[i], c.pending[string(key)])...)
+	}
+	if err := c.deleteChunkKeys(ctx, stale); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		delete(c.pending, string(key))
+	}
+	return nil
+}
+
+// NewBatch returns a batch chunking large values like Put.  The chunks left behind by overwrites are
+// deleted along with the writes of the batch, which reads the keys it writes to find them.
+func (c *ChunkedStore) NewBatch() Batch {
+	return &chunkedBatch{Batch: c.st.NewBatch(), c: c, counts: make(map[string]int)}
+}
+
+type chunkedBatch struct {
+	Batch
+	c *ChunkedStore
+
+	// counts holds the largest number of chunks written by the batch to each key
+	counts map[string]int
+}
+
+// previous returns the number of chunks which the key may hold before a write, either committed or
+// written by the batch.
+func (b *chunkedBatch) previous(ctx context.Context, key []byte) (int, error) {
+	count, err := b.c.chunkCount(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if written := b.counts[string(key)]; written > count {
+		count = written
+	}
+	return count, nil
+}
+
+func (b *chunkedBatch) Put(ctx context.Context, key, value []byte, options ...WriteOption) (err error) {
+	previous, err := b.previous(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var chunks [][]byte
+	if b.c.needsChunks(value) {
+		chunks = b.c.split(value)
+		for i, chunk := range chunks {
+			if err := b.Batch.Put(ctx, chunkKey(key, i), chunk, options...); err != nil {
+				return err
+			}
+		}
+		value = encodeManifest(len(value), len(chunks))
+		if len(chunks) > b.counts[string(key)] {
+			b.counts[string(key)] = len(chunks)
+		}
+	}
+
+	if err := b.Batch.Put(ctx, key, value, options...); err != nil {
+		return err
+	}
+	return b.deleteChunks(ctx, key, len(chunks), previous)
+}
+
+func (b *chunkedBatch) Delete(ctx context.Context, key []byte) (err error) {
+	previous, err := b.previous(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := b.Batch.Delete(ctx, key); err != nil {
+		return err
+	}
+	return b.deleteChunks(ctx, key, 0, previous)
+}
+
+func (b *chunkedBatch) deleteChunks(ctx context.Context, key []byte, from, to int) error {
+	for i := from; i < to; i++ {
+		if err := b.Batch.Delete(ctx, chunkKey(key, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *chunkedBatch) Commit(ctx context.Context) (err error) {
+	if err := b.Batch.Commit(ctx); err != nil {
+		return err
+	}
+	b.counts = make(map[string]int)
+	return nil
+}
+
+// chunkCount returns the number of chunks held by `key`, 0 when it holds none.  The writes which are
+// not flushed yet are not seen.
+func (c *ChunkedStore) chunkCount(ctx context.Context, key []byte) (int, error) {
+	counts, err := c.chunkCounts(ctx, [][]byte{key})
+	if err != nil {
+		return 0, err
+	}
+	return counts[0], nil
+}
+
+// chunkCounts returns the number of chunks held by each of the keys.
+func (c *ChunkedStore) chunkCounts(ctx context.Context, keys [][]byte) ([]int, error) {
+	// Checking the first chunks spares reading the value of the keys which are not chunked
+	firsts := make([][]byte, len(keys))
+	for i, key := range keys {
+		firsts[i] = chunkKey(key, 0)
+	}
+	exists, err := c.st.BatchHas(ctx, firsts)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int, len(keys))
+	for i, key := range keys {
+		if !exists[i] {
+			continue
+		}
+
+		value, err := c.st.Get(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, counts[i], _, err = decodeManifest(value)
+		if err != nil {
+			return nil, fmt.Errorf("read manifest of %s: %w", Key(key), err)
+		}
+	}
+	return counts, nil
+}
+
+// assemble replaces a manifest by the value it stands for.
+func (c *ChunkedStore) assemble(ctx context.Context, kv KV) (KV, error) {
+	size, count, ok, err := decodeManifest(kv.Value)
+	if !ok || err != nil {
+		return kv, err
+	}
+
+	value := make([]byte, 0, size)
+	it := c.st.BatchGet(ctx, chunkKeys(kv.Key, 0, count))
+	defer it.Close()
+	for it.Next() {
+		value = append(value, it.Item().Value...)
+	}
+	if err := it.Err(); err != nil {
+		return kv, fmt.Errorf("read chunks of %s: %w", Key(kv.Key), err)
+	}
+	if len(value) != size {
+		return kv, fmt.Errorf("read chunks of %s: %w", Key(kv.Key), errCorruptedChunks)
+	}
+
+	kv.Value = value
+	return kv, nil
+}
+
+func (c *ChunkedStore) assembleFunc(ctx context.Context) func(KV) (KV, error) {
+	return func(kv KV) (KV, error) {
+		return c.assemble(ctx, kv)
+	}
+}
+
+func (c *ChunkedStore) Get(ctx context.Context, key []byte) (value []byte, err error) {
+	value, err = c.st.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	kv, err := c.assemble(ctx, KV{Key: key, Value: value})
+	return kv.Value, err
+}
+
+func (c *ChunkedStore) Has(ctx context.Context, key []byte) (exists bool, err error) {
+	return c.st.Has(ctx, key)
+}
+
+func (c *ChunkedStore) BatchHas(ctx context.Context, keys [][]byte) (exists []bool, err error) {
+	return c.st.BatchHas(ctx, keys)
+}
+
+func (c *ChunkedStore) BatchGet(ctx context.Context, keys [][]byte, options ...ReadOption) *Iterator {
+	return mapIterator(ctx, c.st.BatchGet(ctx, keys, options...), c.assembleFunc(ctx))
+}
+
+func (c *ChunkedStore) Prefix(ctx context.Context, prefix []byte, limit int, options ...ReadOption) *Iterator {
+	if isChunkKey(prefix) {
+		it := NewIterator(ctx)
+		it.PushFinished()
+		return it
+	}
+
+	// The prefix spans the chunk keys, which must be skipped
+	if bytes.HasPrefix(chunkKeyPrefix, prefix) {
+		return c.scan(ctx, prefix, PrefixEnd(prefix), limit, options)
+	}
+
+	return mapIterator(ctx, c.st.Prefix(ctx, prefix, limit, options...), c.assembleFunc(ctx))
+}
+
+func (c *ChunkedStore) ForEachPrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error, options ...ReadOption) (err error) {
+	return c.st.ForEachPrefix(ctx, prefix, func(key, value []byte) error {
+		if isChunkKey(key) {
+			return nil
+		}
+
+		kv, err := c.assemble(ctx, KV{Key: key, Value: value})
+		if err != nil {
+			return err
+		}
+		return fn(kv.Key, kv.Value)
+	}, options...)
+}
+
+func (c *ChunkedStore) Count(ctx context.Context, prefix []byte) (count int64, err error) {
+	if isChunkKey(prefix) {
+		return 0, nil
+	}
+
+	count, err = c.st.Count(ctx, prefix)
+	if err != nil || !bytes.HasPrefix(chunkKeyPrefix, prefix) {
+		return count, err
+	}
+
+	chunks, err := c.st.Count(ctx, chunkKeyPrefix)
+	return count - chunks, err
+}
+
+func (c *ChunkedStore) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...ReadOption) *Iterator {
+	return c.scan(ctx, start, exclusiveEnd, limit, options)
+}
+
+// outsideChunks splits the range [start, exclusiveEnd) into the ranges before and after the chunk keys.
+func outsideChunks(start, exclusiveEnd []byte) (ranges [][2][]byte) {
+	if bytes.Compare(start, chunkKeyPrefix) < 0 {
+		if BeforeEnd(chunkKeyPrefix, exclusiveEnd) {
+			ranges = append(ranges, [2][]byte{start, chunkKeyPrefix})
+		} else {
+			ranges = append(ranges, [2][]byte{start, exclusiveEnd})
+		}
+	}
+
+	if BeforeEnd(chunkKeyEnd, exclusiveEnd) {
+		if bytes.Compare(start, chunkKeyEnd) < 0 {
+			start = chunkKeyEnd
+		}
+		ranges = append(ranges, [2][]byte{start, exclusiveEnd})
+	}
+	return ranges
+}
+
+// scan chains the scans of the ranges around the chunk keys.
+func (c *ChunkedStore) scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options []ReadOption) *Iterator {
+	readOptions := ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
+	ranges := outsideChunks(start, exclusiveEnd)
+	if readOptions.Reverse {
+		for i, j := 0, len(ranges)-1; i < j; i, j = i+1, j-1 {
+			ranges[i], ranges[j] = ranges[j], ranges[i]
+		}
+	}
+
+	out := NewIterator(ctx)
+	go func() {
+		count := 0
+		for _, r := range ranges {
+			remaining := 0
+			if Limit(limit).Bounded() {
+				remaining = limit - count
+				if remaining <= 0 {
+					break
+				}
+			}
+
+			it := c.st.Scan(ctx, r[0], r[1], remaining, options...)
+			for it.Next() {
+				item, err := c.assemble(ctx, it.Item())
+				if err != nil {
+					it.Close()
+					out.PushError(err)
+					return
+				}
+				if !out.PushItem(item) {
+					it.Close()
+					return
+				}
+				count++
+			}
+			if err := it.Err(); err != nil {
+				out.PushError(err)
+				return
+			}
+		}
+		out.PushFinished()
+	}()
+	return out
+}
+
+func (c *ChunkedStore) PrefixPage(ctx context.Context, prefix []byte, pageSize int, token string, options ...ReadOption) (items []KV, next string, err error) {
+	page, next, err := c.st.PrefixPage(ctx, prefix, pageSize, token, options...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	items = page[:0]
+	for _, item := range page {
+		if isChunkKey(item.Key) {
+			continue
+		}
+
+		item, err = c.assemble(ctx, item)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, item)
+	}
+	return items, next, nil
+}
+
+func (c *ChunkedStore) Delete(ctx context.Context, key []byte) (err error) {
+	count, err := c.chunkCount(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := c.st.Delete(ctx, key); err != nil {
+		return err
+	}
+	return c.deleteChunkKeys(ctx, chunkKeys(key, 0, count))
+}
+
+func (c *ChunkedStore) BatchDelete(ctx context.Context, keys [][]byte) (err error) {
+	counts, err := c.chunkCounts(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	var chunks [][]byte
+	for i, key := range keys {
+		chunks = append(chunks, chunkKeys(key, 0, counts[i])...)
+	}
+
+	if err := c.st.BatchDelete(ctx, keys); err != nil {
+		return err
+	}
+	return c.deleteChunkKeys(ctx, chunks)
+}
+
+func (c *ChunkedStore) DeletePrefix(ctx context.Context, prefix []byte) (err error) {
+	if isChunkKey(prefix) {
+		return nil
+	}
+
+	if bytes.HasPrefix(chunkKeyPrefix, prefix) {
+		return c.DeleteRange(ctx, prefix, PrefixEnd(prefix))
+	}
+
+	chunks, err := c.rangeChunks(ctx, prefix, PrefixEnd(prefix))
+	if err != nil {
+		return err
+	}
+	if err := c.st.DeletePrefix(ctx, prefix); err != nil {
+		return err
+	}
+	return c.deleteChunkKeys(ctx, chunks)
+}
+
+func (c *ChunkedStore) DeleteRange(ctx context.Context, start, exclusiveEnd []byte) (err error) {
+	for _, r := range outsideChunks(start, exclusiveEnd) {
+		chunks, err := c.rangeChunks(ctx, r[0], r[1])
+		if err != nil {
+			return err
+		}
+		if err := c.st.DeleteRange(ctx, r[0], r[1]); err != nil {
+			return err
+		}
+		if err := c.deleteChunkKeys(ctx, chunks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeChunks returns the keys of the chunks held by the keys of [start, exclusiveEnd).
+func (c *ChunkedStore) rangeChunks(ctx context.Context, start, exclusiveEnd []byte) (chunks [][]byte, err error) {
+	if err := c.FlushPuts(ctx); err != nil {
+		return nil, err
+	}
+
+	err = ForEach(c.st.Scan(ctx, start, exclusiveEnd, 0), func(key, value []byte) error {
+		_, count, ok, err := decodeManifest(value)
+		if ok && err == nil {
+			chunks = append(chunks, chunkKeys(key, 0, count)...)
+		}
+		return err
+	})
+	return chunks, err
+}
+
+func (c *ChunkedStore) deleteChunkKeys(ctx context.Context, chunks [][]byte) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	return c.st.BatchDelete(ctx, chunks)
+}
+
+// Close closes the wrapped store.
+func (c *ChunkedStore) Close() error {
+	return c.st.Close()
+}
+
+// GetReader returns a reader over the value of `key`, which reads the chunks of a chunked value one at
+// a time instead of reassembling it.  Returns `kdb.ErrNotFound` if not found.
+func (c *ChunkedStore) GetReader(ctx context.Context, key []byte) (io.ReadCloser, error) {
+	value, err := c.st.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	size, count, ok, err := decodeManifest(value)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return io.NopCloser(bytes.NewReader(value)), nil
+	}
+
+	return &chunkReader{ctx: ctx, c: c, key: key, size: size, count: count}, nil
+}
+
+type chunkReader struct {
+	ctx   context.Context
+	c     *ChunkedStore
+	key   []byte
+	size  int
+	count int
+
+	next int
+	read int
+	buf  []byte
+}
+
+func (r *chunkReader) Read(p []byte) (n int, err error) {
+	for len(r.buf) == 0 {
+		if r.next == r.count {
+			if r.read != r.size {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, io.EOF
+		}
+
+		r.buf, err = r.c.st.Get(r.ctx, chunkKey(r.key, r.next))
+		if err != nil {
+			return 0, fmt.Errorf("read chunk %d of %s: %w", r.next, Key(r.key), err)
+		}
+		r.next++
+	}
+
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.read += n
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.buf = nil
+	return nil
+}
+
+// PutWriter returns a writer storing a value under `key` as it is written, chunk by chunk.  The value
+// is only complete once the writer is closed.  Like Put, the value is fully written after a call to
+// FlushPuts().
+func (c *ChunkedStore) PutWriter(ctx context.Context, key []byte, options ...WriteOption) (io.WriteCloser, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+
+	// The chunks previously held by the key are known now, as they are going to be overwritten
+	previous, err := c.previous(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c.writers[string(key)]++
+
+	return &chunkWriter{ctx: ctx, c: c, key: key, options: options, previous: previous}, nil
+}
+
+type chunkWriter struct {
+	ctx      context.Context
+	c        *ChunkedStore
+	key      []byte
+	options  []WriteOption
+	previous int
+
+	count  int
+	size   int
+	buf    []byte
+	closed bool
+}
+
+func (w *chunkWriter) Write(p []byte) (n int, err error) {
+	if w.closed {
+		return 0, errors.New("write to closed writer")
+	}
+
+	w.buf = append(w.buf, p...)
+	w.size += len(p)
+
+	// A chunk is only written once more data follows it, so that a small value is never chunked
+	for len(w.buf) > w.c.chunkSize {
+		if err := w.putChunk(w.buf[:w.c.chunkSize]); err != nil {
+			return 0, err
+		}
+		w.buf = append([]byte(nil), w.buf[w.c.chunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (w *chunkWriter) putChunk(chunk []byte) error {
+	w.c.lk.Lock()
+	defer w.c.lk.Unlock()
+
+	if err := w.c.st.Put(w.ctx, chunkKey(w.key, w.count), chunk, w.options...); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Close writes the manifest, or the value when it fits in a chunk.  The chunks left behind by the
+// previous value are deleted by the next FlushPuts.
+func (w *chunkWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.release()
+
+	if w.count == 0 && !w.c.needsChunks(w.buf) {
+		if err := w.c.st.Put(w.ctx, w.key, w.buf, w.options...); err != nil {
+			return err
+		}
+	} else {
+		if len(w.buf) > 0 {
+			if err := w.putChunk(w.buf); err != nil {
+				return err
+			}
+		}
+		if err := w.c.st.Put(w.ctx, w.key, encodeManifest(w.size, w.count), w.options...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release records the chunks written, and lets FlushPuts delete the stale chunks of the key.
+func (w *chunkWriter) release() {
+	w.c.lk.Lock()
+	defer w.c.lk.Unlock()
+
+	w.c.writers[string(w.key)]--
+	if w.c.writers[string(w.key)] == 0 {
+		delete(w.c.writers, string(w.key))
+	}
+	w.c.written(w.key, w.previous, w.count)
+}
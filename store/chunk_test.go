@@ -0,0 +1,91 @@
+package store_test
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+func TestChunkedStore(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	chunked := store.WithChunking(st, 4)
+	countChunks := func() int64 {
+		n, err := st.Count(ctx, []byte("\xff\xffchunk/"))
+		require.NoError(t, err)
+		return n
+	}
+
+	require.NoError(t, chunked.Put(ctx, []byte("a"), []byte("abc")))
+	require.NoError(t, chunked.Put(ctx, []byte("b"), []byte("0123456789")))
+	require.NoError(t, chunked.Put(ctx, []byte("c"), []byte("\x00kdb-chunked\x00")))
+	require.NoError(t, chunked.FlushPuts(ctx))
+	require.Equal(t, int64(3+4), countChunks())
+
+	value, err := chunked.Get(ctx, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, "0123456789", string(value))
+
+	items, err := chunked.BatchGet(ctx, [][]byte{[]byte("c"), []byte("a")}).Collect()
+	require.NoError(t, err)
+	require.Equal(t, []store.KV{{Key: []byte("c"), Value: []byte("\x00kdb-chunked\x00")}, {Key: []byte("a"), Value: []byte("abc")}}, items)
+
+	items, err = chunked.Prefix(ctx, nil, 0, store.Reverse()).Collect()
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+	require.Equal(t, store.KV{Key: []byte("b"), Value: []byte("0123456789")}, items[1])
+
+	n, err := chunked.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	// Overwrites delete the chunks left behind
+	require.NoError(t, chunked.Put(ctx, []byte("b"), []byte("01234")))
+	require.NoError(t, chunked.FlushPuts(ctx))
+	require.Equal(t, int64(2+4), countChunks())
+	require.NoError(t, chunked.Put(ctx, []byte("c"), nil))
+	require.NoError(t, chunked.FlushPuts(ctx))
+	require.Equal(t, int64(2), countChunks())
+
+	w, err := chunked.PutWriter(ctx, []byte("d"))
+	require.NoError(t, err)
+	for _, part := range []string{"hello", " ", "chunked", " world"} {
+		_, err = w.Write([]byte(part))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, chunked.FlushPuts(ctx))
+
+	r, err := chunked.GetReader(ctx, []byte("d"))
+	require.NoError(t, err)
+	value, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "hello chunked world", string(value))
+
+	require.NoError(t, chunked.Delete(ctx, []byte("d")))
+	require.Equal(t, int64(2), countChunks())
+
+	// Until flushed, an overwrite leaves the previous value readable, and other pending writes as they are
+	require.NoError(t, st.Put(ctx, []byte("raw"), []byte("1")))
+	require.NoError(t, chunked.Put(ctx, []byte("b"), []byte("0123456789abcdef")))
+	require.NoError(t, chunked.Put(ctx, []byte("b"), []byte("0")))
+	value, err = chunked.Get(ctx, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, "01234", string(value))
+	exists, err := st.Has(ctx, []byte("raw"))
+	require.NoError(t, err)
+	require.False(t, exists)
+	require.NoError(t, chunked.FlushPuts(ctx))
+	value, err = chunked.Get(ctx, []byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, "0", string(value))
+	require.Equal(t, int64(0), countChunks())
+
+	require.NoError(t, chunked.DeletePrefix(ctx, nil))
+	require.Equal(t, int64(0), countChunks())
+
+	require.NoError(t, chunked.Close())
+}
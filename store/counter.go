@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Counter is implemented by stores able to increment integer values atomically.
+type Counter interface {
+	// Incr adds delta, which may be negative, to the counter stored at key and returns its new value.
+	// A missing key counts as 0.  Counters are stored as base 10 strings.
+	Incr(ctx context.Context, key []byte, delta int64) (int64, error)
+}
+
+// IncrVersioned implements `Counter.Incr` on top of `Update`, for stores without native counters.
+// Unlike `Update`, it retries on concurrent modifications until ctx is done.
+func IncrVersioned(ctx context.Context, st Versioned, key []byte, delta int64) (value int64, err error) {
+	err = update(ctx, st, key, 0, func(old []byte, exists bool) (_ []byte, err error) {
+		value = 0
+		if exists {
+			if value, err = ParseCounter(old); err != nil {
+				return nil, err
+			}
+		}
+
+		value += delta
+		return []byte(strconv.FormatInt(value, 10)), nil
+	})
+	return value, err
+}
+
+// ParseCounter decodes the value of a counter.
+func ParseCounter(value []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid counter value %q: %w", value, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,34 @@
+package store
+
+import "context"
+
+// Cursorer is implemented by stores keeping their keys ordered, which can open a `Cursor`.
+type Cursorer interface {
+	// NewCursor opens an unpositioned cursor over the whole keyspace. The `KeyOnly()` read option
+	// skips fetching values. The cursor must be closed once done with.
+	NewCursor(ctx context.Context, options ...ReadOption) (Cursor, error)
+}
+
+// Cursor walks the keys of a store in both directions and can be repositioned at any time, unlike
+// `Iterator` which streams a single forward result set.
+//
+// An unpositioned cursor (freshly opened, or moved past either end of the keyspace) starts from the
+// first key on `Next()` and from the last key on `Prev()`.
+//
+// `Key()` and `Value()` are valid until the next call moving the cursor. A cursor is not safe for
+// concurrent use.
+type Cursor interface {
+	// Seek positions the cursor on the first key greater or equal to `key`, and reports whether there is one.
+	Seek(key []byte) bool
+	// Next moves the cursor to the following key, and reports whether there is one.
+	Next() bool
+	// Prev moves the cursor to the preceding key, and reports whether there is one.
+	Prev() bool
+
+	Key() []byte
+	Value() []byte
+
+	// Err returns the error which made the last move fail, if any.
+	Err() error
+	Close() error
+}
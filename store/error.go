@@ -4,4 +4,8 @@ import "errors"
 
 var (
 	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when committing a transaction whose reads were modified concurrently.
+	ErrConflict = errors.New("conflict")
+	// ErrNotDurable is returned when a write asking for `Sync()` could not be made durable in time.
+	ErrNotDurable = errors.New("not durable")
 )
@@ -0,0 +1,142 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	clientV3 "go.etcd.io/etcd/client/v3"
+	"time"
+)
+
+// NewBatch returns a batch holding its writes until Commit, which applies them in transactions of at
+// most `maxTxnOps` operations and `maxTxnBytes` bytes: a batch is applied atomically as long as it
+// fits in one transaction.
+func (s *Store) NewBatch() store.Batch {
+	return s.newBatch()
+}
+
+func (s *Store) newBatch() *batch {
+	return &batch{db: s.db, compression: s.compression}
+}
+
+type batch struct {
+	db          *clientV3.Client
+	compression store.Compressor
+
+	writes []*pendingWrite
+	size   int
+}
+
+type pendingWrite struct {
+	store.KV
+	ttl     time.Duration
+	deleted bool
+}
+
+func (b *batch) Put(_ context.Context, key, value []byte, options ...store.WriteOption) (err error) {
+	writeOptions := store.WriteOptions{}
+	for _, opt := range options {
+		opt.Apply(&writeOptions)
+	}
+
+	b.writes = append(b.writes, &pendingWrite{
+		KV:  store.KV{Key: key, Value: b.compression.Compress(value)},
+		ttl: writeOptions.TTL,
+	})
+	b.size += len(key) + len(value)
+	return nil
+}
+
+func (b *batch) Delete(_ context.Context, key []byte) (err error) {
+	b.writes = append(b.writes, &pendingWrite{
+		KV:      store.KV{Key: key},
+		deleted: true,
+	})
+	b.size += len(key)
+	return nil
+}
+
+func (b *batch) Len() int {
+	return len(b.writes)
+}
+
+func (b *batch) Size() int {
+	return b.size
+}
+
+// Commit keeps the writes when it fails, so that it can be retried.
+func (b *batch) Commit(ctx context.Context) (err error) {
+	if len(b.writes) == 0 {
+		return nil
+	}
+	log.Debugw("committing batch", "len", len(b.writes))
+
+	// Etcd rejects the transactions writing a key twice, so only the last write of each key is kept
+	last := make(map[string]int, len(b.writes))
+	for i, w := range b.writes {
+		last[string(w.Key)] = i
+	}
+
+	// Keys sharing the same TTL share a lease, which starts when the batch is committed
+	leases := make(map[time.Duration]clientV3.LeaseID)
+	ops := make([]clientV3.Op, 0, len(last))
+	sizes := make([]int, 0, len(last))
+	for i, w := range b.writes {
+		if last[string(w.Key)] != i {
+			continue
+		}
+
+		key := store.Key(w.Key).String()
+		if w.deleted {
+			ops = append(ops, clientV3.OpDelete(key))
+			sizes = append(sizes, len(key))
+			continue
+		}
+
+		var opts []clientV3.OpOption
+		if w.ttl > 0 {
+			lease, ok := leases[w.ttl]
+			if !ok {
+				resp, err := b.db.Grant(ctx, ttlSeconds(w.ttl))
+				if err != nil {
+					return fmt.Errorf("grant lease: %w", err)
+				}
+				lease = resp.ID
+				leases[w.ttl] = lease
+			}
+			opts = append(opts, clientV3.WithLease(lease))
+		}
+		ops = append(ops, clientV3.OpPut(key, string(w.Value), opts...))
+		sizes = append(sizes, len(key)+len(w.Value))
+	}
+
+	for _, txn := range splitTxn(ops, sizes) {
+		if _, err := b.db.KV.Txn(ctx).Then(txn...).Commit(); err != nil {
+			return err
+		}
+	}
+
+	b.writes, b.size = nil, 0
+	return nil
+}
+
+// splitTxn groups ops in transactions of at most `maxTxnOps` operations and `maxTxnBytes` bytes, an
+// operation bigger than `maxTxnBytes` going alone in its transaction.
+func splitTxn(ops []clientV3.Op, sizes []int) (txns [][]clientV3.Op) {
+	start, bytes := 0, 0
+	for i := range ops {
+		if i > start && (i-start == maxTxnOps || bytes+sizes[i] > maxTxnBytes) {
+			txns = append(txns, ops[start:i])
+			start, bytes = i, 0
+		}
+		bytes += sizes[i]
+	}
+	if start < len(ops) {
+		txns = append(txns, ops[start:])
+	}
+	return txns
+}
+
+func (b *batch) Discard() {
+	b.writes, b.size = nil, 0
+}
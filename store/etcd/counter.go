@@ -0,0 +1,14 @@
+package etcd
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+)
+
+var _ store.Counter = (*Store)(nil)
+
+// Incr compares and swaps the counter on its revision, retried when another writer modified it.
+func (s *Store) Incr(ctx context.Context, key []byte, delta int64) (int64, error) {
+	log.Debugw("incrementing", "key", store.Key(key), "delta", delta)
+	return store.IncrVersioned(ctx, s, key, delta)
+}
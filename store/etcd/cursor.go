@@ -0,0 +1,150 @@
+package etcd
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	clientV3 "go.etcd.io/etcd/client/v3"
+)
+
+const (
+	cursorPageSize = 100
+)
+
+var _ store.Cursorer = (*Store)(nil)
+
+// NewCursor opens a cursor loading keys by pages of range requests, following the cursor moves.
+// Each page reflects the state of the database at the time it is loaded.
+func (s *Store) NewCursor(ctx context.Context, options ...store.ReadOption) (store.Cursor, error) {
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
+	return &cursor{
+		ctx:     ctx,
+		s:       s,
+		keyOnly: readOptions.KeyOnly,
+	}, nil
+}
+
+// cursor holds a page of keys in ascending order, loading the neighbouring page when moving past
+// either of its ends.
+type cursor struct {
+	ctx     context.Context
+	s       *Store
+	keyOnly bool
+
+	page []store.KV
+	pos  int
+	err  error
+}
+
+func (c *cursor) Seek(key []byte) bool {
+	start, end := encodeRange(key, nil)
+	return c.load(start, false, clientV3.WithRange(end))
+}
+
+func (c *cursor) Next() bool {
+	if c.page == nil {
+		return c.Seek(nil)
+	}
+
+	if c.pos+1 < len(c.page) {
+		c.pos++
+		return true
+	}
+
+	// The smallest key after the last one of the page, every key being hex encoded
+	after := store.Key(c.page[len(c.page)-1].Key).String() + "\x00"
+	return c.load(after, false, clientV3.WithFromKey())
+}
+
+func (c *cursor) Prev() bool {
+	if c.page == nil {
+		return c.load("\x00", true, clientV3.WithFromKey())
+	}
+
+	if c.pos > 0 {
+		c.pos--
+		return true
+	}
+
+	return c.load("\x00", true, clientV3.WithRange(store.Key(c.page[0].Key).String()))
+}
+
+// load replaces the current page by the result of the given range request, taking its first key in
+// ascending order, or its last key when loading backward.
+func (c *cursor) load(key string, backward bool, ops ...clientV3.OpOption) bool {
+	c.page, c.pos = nil, 0
+
+	ops = append(ops, clientV3.WithLimit(cursorPageSize))
+	if backward {
+		ops = append(ops, clientV3.WithSort(clientV3.SortByKey, clientV3.SortDescend))
+	}
+	if c.keyOnly {
+		ops = append(ops, clientV3.WithKeysOnly())
+	}
+
+	resp, err := c.s.db.KV.Get(c.ctx, key, ops...)
+	if err != nil {
+		c.err = err
+		return false
+	}
+
+	page := make([]store.KV, len(resp.Kvs))
+	for i, kv := range resp.Kvs {
+		k, err := decodeKey(kv.Key)
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		item := store.KV{Key: k}
+		if !c.keyOnly {
+			item.Value, err = c.s.compression.Decompress(kv.Value)
+			if err != nil {
+				c.err = err
+				return false
+			}
+		}
+
+		if backward {
+			page[len(page)-1-i] = item
+		} else {
+			page[i] = item
+		}
+	}
+
+	if len(page) == 0 {
+		return false
+	}
+
+	c.page = page
+	if backward {
+		c.pos = len(page) - 1
+	}
+	return true
+}
+
+func (c *cursor) Key() []byte {
+	if c.page == nil {
+		return nil
+	}
+	return c.page[c.pos].Key
+}
+
+func (c *cursor) Value() []byte {
+	if c.page == nil {
+		return nil
+	}
+	return c.page[c.pos].Value
+}
+
+func (c *cursor) Err() error {
+	return c.err
+}
+
+func (c *cursor) Close() error {
+	c.page = nil
+	return nil
+}
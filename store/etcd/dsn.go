@@ -2,6 +2,7 @@ package etcd
 
 import (
 	"fmt"
+	"github.com/bitrainforest/kdb/store"
 	"net/url"
 	"strconv"
 	"strings"
@@ -11,8 +12,9 @@ type dsn struct {
 	endpoints   []string
 	username    string
 	password    string
-	compression string // none, zstd
-	threshold   int    // compression threshold in bytes
+	compression string            // none, zstd
+	threshold   int               // compression threshold in bytes
+	batch       store.BatchPolicy // when to flush the pending writes
 }
 
 func newDSN(dsnString string) (*dsn, error) {
@@ -43,5 +45,10 @@ func newDSN(dsnString string) (*dsn, error) {
 			d.threshold = i
 		}
 	}
+
+	d.batch, err = store.ParseBatchPolicy(u.Query(), defaultBatchPolicy)
+	if err != nil {
+		return nil, err
+	}
 	return d, nil
 }
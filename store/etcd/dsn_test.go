@@ -1,9 +1,11 @@
 package etcd
 
 import (
+	"github.com/bitrainforest/kdb/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func Test_parseDNS(t *testing.T) {
@@ -19,6 +21,7 @@ func Test_parseDNS(t *testing.T) {
 			expectError: false,
 			expectDSN: &dsn{
 				endpoints: []string{"localhost:2379"},
+				batch:     defaultBatchPolicy,
 			},
 		},
 		{
@@ -29,6 +32,7 @@ func Test_parseDNS(t *testing.T) {
 				endpoints: []string{"localhost:2379"},
 				username:  "username",
 				password:  "password",
+				batch:     defaultBatchPolicy,
 			},
 		},
 		{
@@ -41,6 +45,7 @@ func Test_parseDNS(t *testing.T) {
 				password:    "password",
 				compression: "zstd",
 				threshold:   64,
+				batch:       defaultBatchPolicy,
 			},
 		},
 		{
@@ -53,8 +58,31 @@ func Test_parseDNS(t *testing.T) {
 				password:    "password",
 				compression: "zstd",
 				threshold:   64,
+				batch:       defaultBatchPolicy,
 			},
 		},
+		{
+			name:        "batching",
+			dns:         "etcd://localhost:2379?batch_size=100&flush_interval=1s",
+			expectError: false,
+			expectDSN: &dsn{
+				endpoints: []string{"localhost:2379"},
+				batch:     store.BatchPolicy{Size: 100, FlushInterval: time.Second},
+			},
+		},
+		{
+			name:        "unlimited batch",
+			dns:         "etcd://localhost:2379?batch_size=0",
+			expectError: false,
+			expectDSN: &dsn{
+				endpoints: []string{"localhost:2379"},
+			},
+		},
+		{
+			name:        "invalid batch size",
+			dns:         "etcd://localhost:2379?batch_size=-1",
+			expectError: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -2,24 +2,50 @@ package etcd
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"github.com/bitrainforest/kdb/store"
 	logging "github.com/ipfs/go-log"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientV3 "go.etcd.io/etcd/client/v3"
 	"sync"
+	"time"
 )
 
 const (
 	maxBatchLen = 500
+	// maxTxnOps is the default limit of operations in a transaction of etcd servers (`--max-txn-ops`)
+	maxTxnOps = 128
+	// maxTxnBytes keeps a transaction under the default request size limit of etcd servers
+	// (`--max-request-bytes`, 1.5 MiB), leaving room for the encoding overhead
+	maxTxnBytes = 1 << 20
 )
 
 var log = logging.Logger("kdb/etcd")
 
+// defaultBatchPolicy flushes the pending writes every `maxBatchLen` writes, unless the DSN says otherwise.
+var defaultBatchPolicy = store.BatchPolicy{Size: maxBatchLen}
+
 type Store struct {
-	dsn         string
+	reader
+	dsn        string
+	writeBatch *batch
+	writeLk    sync.Mutex
+	policy     store.BatchPolicy
+	flusher    *store.AutoFlusher
+}
+
+// reader serves the reads of a Store, or of a snapshot.
+type reader struct {
 	db          *clientV3.Client
 	compression store.Compressor
-	writeBatch  []*store.KV
-	writeLk     sync.Mutex
+	// ops are added to every read request, pinning the revision read by snapshots
+	ops []clientV3.OpOption
+}
+
+// withOps appends the reader options to ops.
+func (r reader) withOps(ops ...clientV3.OpOption) []clientV3.OpOption {
+	return append(ops, r.ops...)
 }
 
 func NewStore(dsnString string) (store.Store, error) {
@@ -44,51 +70,71 @@ func NewStore(dsnString string) (store.Store, error) {
 		return nil, err
 	}
 
-	return &Store{
-		dsn:         dsnString,
-		db:          client,
-		compression: compression,
-	}, nil
+	s := &Store{
+		reader: reader{
+			db:          client,
+			compression: compression,
+		},
+		dsn:    dsnString,
+		policy: dsn.batch,
+	}
+	s.flusher = store.NewAutoFlusher(dsn.batch.FlushInterval, s.flush)
+	return s, nil
+}
+
+var _ store.FlushErrorNotifier = (*Store)(nil)
 
+func (s *Store) OnFlushError(fn func(err error)) {
+	s.flusher.SetErrorHandler(fn)
 }
 
-func (s *Store) Put(ctx context.Context, key, value []byte) (err error) {
+func (s *Store) Put(ctx context.Context, key, value []byte, options ...store.WriteOption) (err error) {
 	log.Debugw("putting", "key", store.Key(key))
-	value = s.compression.Compress(value)
 	s.writeLk.Lock()
-	s.writeBatch = append(s.writeBatch, &store.KV{
-		Key:   key,
-		Value: value,
-	})
+	if s.writeBatch == nil {
+		s.writeBatch = s.newBatch()
+	}
+	err = s.writeBatch.Put(ctx, key, value, options...)
+	full := s.policy.Full(s.writeBatch)
 	s.writeLk.Unlock()
-	if len(s.writeBatch) >= maxBatchLen {
-		return s.FlushPuts(ctx)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if full {
+		if err := s.flush(ctx); err != nil {
+			return err
+		}
+	}
+	return s.flusher.Err()
 }
 
-func (s *Store) FlushPuts(_ context.Context) (err error) {
+// FlushPuts writes the pending puts.  Etcd acknowledges a write once a quorum of the cluster has
+// persisted it, so flushed writes are always durable and the `Sync()` option has no effect.
+func (s *Store) FlushPuts(ctx context.Context) (err error) {
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	return s.flusher.Err()
+}
+
+func (s *Store) flush(ctx context.Context) error {
 	s.writeLk.Lock()
 	defer s.writeLk.Unlock()
-	if len(s.writeBatch) == 0 {
+	if s.writeBatch == nil {
 		return nil
 	}
-	log.Debugw("flushing", "len", len(s.writeBatch))
+	return s.writeBatch.Commit(ctx)
+}
 
-	for _, kv := range s.writeBatch {
-		_, err := s.db.KV.Put(context.Background(), store.Key(kv.Key).String(), string(kv.Value))
-		if err != nil {
-			return err
-		}
-	}
-	s.writeBatch = nil
-	return err
+// ttlSeconds rounds ttl up to the second, the granularity of etcd leases.
+func ttlSeconds(ttl time.Duration) int64 {
+	return int64((ttl + time.Second - 1) / time.Second)
 }
 
-func (s *Store) Get(ctx context.Context, key []byte) (value []byte, err error) {
+func (r reader) Get(ctx context.Context, key []byte) (value []byte, err error) {
 	log.Debugw("getting", "key", store.Key(key))
-	res, err := s.db.KV.Get(ctx, store.Key(key).String())
+	res, err := r.db.KV.Get(ctx, store.Key(key).String(), r.ops...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,12 +152,52 @@ func (s *Store) Get(ctx context.Context, key []byte) (value []byte, err error) {
 		})
 	}
 
-	return s.compression.Decompress(kvs[0].Value)
+	return r.compression.Decompress(kvs[0].Value)
+}
+
+func (r reader) Has(ctx context.Context, key []byte) (exists bool, err error) {
+	res, err := r.db.KV.Get(ctx, store.Key(key).String(), r.withOps(clientV3.WithCountOnly())...)
+	if err != nil {
+		return false, err
+	}
+	return res.Count > 0, nil
+}
+
+// BatchHas sends the count requests `maxTxnOps` at a time in a transaction.
+func (r reader) BatchHas(ctx context.Context, keys [][]byte) (exists []bool, err error) {
+	log.Debugw("batch has", "key_count", len(keys))
+	exists = make([]bool, 0, len(keys))
+	for len(keys) > 0 {
+		chunk := keys
+		if len(chunk) > maxTxnOps {
+			chunk = chunk[:maxTxnOps]
+		}
+		keys = keys[len(chunk):]
+
+		ops := make([]clientV3.Op, len(chunk))
+		for i, key := range chunk {
+			ops[i] = clientV3.OpGet(store.Key(key).String(), r.withOps(clientV3.WithCountOnly())...)
+		}
+
+		resp, err := r.db.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range resp.Responses {
+			exists = append(exists, op.GetResponseRange().Count > 0)
+		}
+	}
+	return exists, nil
 }
 
-func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
+func (r reader) BatchGet(ctx context.Context, keys [][]byte, options ...store.ReadOption) *store.Iterator {
 	log.Debugw("batch getting", "keys", keys)
 
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
 	kr := store.NewIterator(ctx)
 
 	go func() {
@@ -122,120 +208,79 @@ func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
 				return
 			default:
 			}
-			value, err := s.Get(ctx, key)
+			value, err := r.Get(ctx, key)
+			if err == store.ErrNotFound && readOptions.AllowMissing {
+				value, err = nil, nil
+			}
 			if err != nil {
 				kr.PushError(err)
 				return
 			}
-			kr.PushItem(store.KV{
+			if !kr.PushItem(store.KV{
 				Key: key, Value: value,
-			})
+			}) {
+				return
+			}
 		}
 
 	}()
 	return kr
 }
 
-//func (s *Store) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...store.ReadOption) *store.Iterator {
-//	sit := store.NewIterator(ctx)
-//	log.Debugw("scanning", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd), "limit", store.Limit(limit))
-//
-//	readOptions := store.ReadOptions{}
-//	for _, opt := range options {
-//		opt.Apply(&readOptions)
-//	}
-//
-//	var ops = []clientV3.OpOption{
-//		clientV3.WithRange(store.Key(exclusiveEnd).String()),
-//		clientV3.WithLimit(int64(limit)),
-//	}
-//
-//	if readOptions.KeyOnly {
-//		ops = append(ops, clientV3.WithKeysOnly())
-//	}
-//
-//	go func() {
-//		defer sit.PushFinished()
-//		resp, err := s.db.KV.Get(ctx, store.Key(start).String(), ops...)
-//		if err != nil {
-//			sit.PushError(err)
-//			return
-//		}
-//		if resp.Count == 0 {
-//			sit.PushError(store.ErrNotFound)
-//			return
-//		}
-//		for _, kv := range resp.Kvs {
-//			select {
-//			case <-ctx.Done():
-//				return
-//			default:
-//			}
-//			if readOptions.KeyOnly {
-//				sit.PushItem(store.KV{
-//					Key: kv.Key,
-//				})
-//			} else {
-//				sit.PushItem(store.KV{
-//					Key:   kv.Key,
-//					Value: kv.Value,
-//				})
-//			}
-//		}
-//	}()
-//
-//	return sit
-//}
-
-func (s *Store) Prefix(ctx context.Context, prefix []byte, limit int, options ...store.ReadOption) *store.Iterator {
-	sit := store.NewIterator(ctx)
+func (r reader) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...store.ReadOption) *store.Iterator {
+	log.Debugw("scanning", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd), "limit", store.Limit(limit))
+
+	key, end := encodeRange(start, exclusiveEnd)
+	return r.rangeIterator(ctx, key, store.Limit(limit), options, clientV3.WithRange(end))
+}
+
+func (r reader) Prefix(ctx context.Context, prefix []byte, limit int, options ...store.ReadOption) *store.Iterator {
 	log.Debugw("prefix scanning", "prefix", store.Key(prefix), "limit", store.Limit(limit))
 
-	readOptions := store.ReadOptions{}
-	for _, opt := range options {
-		opt.Apply(&readOptions)
-	}
+	return r.rangeIterator(ctx, store.Key(prefix).String(), store.Limit(limit), options, clientV3.WithPrefix())
+}
 
-	var ops = []clientV3.OpOption{
-		clientV3.WithPrefix(),
-		clientV3.WithLimit(int64(limit)),
+func (s *Store) PrefixPage(ctx context.Context, prefix []byte, pageSize int, token string, options ...store.ReadOption) (items []store.KV, next string, err error) {
+	log.Debugw("prefix paging", "prefix", store.Key(prefix), "page_size", pageSize)
+
+	return store.ScanPage(ctx, s, prefix, pageSize, token, options...)
+}
+
+func (r reader) Count(ctx context.Context, prefix []byte) (count int64, err error) {
+	log.Debugw("counting", "prefix", store.Key(prefix))
+	resp, err := r.db.KV.Get(ctx, store.Key(prefix).String(), r.withOps(clientV3.WithPrefix(), clientV3.WithCountOnly())...)
+	if err != nil {
+		return 0, err
 	}
+	return resp.Count, nil
+}
 
-	if readOptions.KeyOnly {
-		ops = append(ops, clientV3.WithKeysOnly())
+// rangeIterator streams the result of a range request starting at `key`, the extent of the range being given by `ops`.
+func (r reader) rangeIterator(ctx context.Context, key string, limit store.Limit, options []store.ReadOption, ops ...clientV3.OpOption) *store.Iterator {
+	sit := store.NewIterator(ctx)
+
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
 	}
+	ops = r.rangeOps(limit, readOptions, ops...)
 
 	go func() {
 		defer sit.PushFinished()
-		resp, err := s.db.KV.Get(ctx, store.Key(prefix).String(), ops...)
+		resp, err := r.db.KV.Get(ctx, key, ops...)
 		if err != nil {
 			sit.PushError(err)
 			return
 		}
-		if resp.Count == 0 {
-			sit.PushError(store.ErrNotFound)
-			return
-		}
 		for _, kv := range resp.Kvs {
-			select {
-			case <-ctx.Done():
+			item, err := r.decodeKV(kv, readOptions.KeyOnly)
+			if err != nil {
+				sit.PushError(err)
 				return
-			default:
 			}
-			if readOptions.KeyOnly {
-				sit.PushItem(store.KV{
-					Key: kv.Key,
-				})
-			} else {
-				dec, err := s.compression.Decompress(kv.Value)
-				if err != nil {
-					sit.PushError(err)
-					return
-				}
-				sit.PushItem(store.KV{
-					Key:   kv.Key,
-					Value: dec,
-				})
+
+			if !sit.PushItem(item) {
+				return
 			}
 		}
 	}()
@@ -243,6 +288,84 @@ func (s *Store) Prefix(ctx context.Context, prefix []byte, limit int, options ..
 	return sit
 }
 
+func (r reader) ForEachPrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error, options ...store.ReadOption) (err error) {
+	log.Debugw("prefix iterating", "prefix", store.Key(prefix))
+
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
+	resp, err := r.db.KV.Get(ctx, store.Key(prefix).String(), r.rangeOps(store.Limit(0), readOptions, clientV3.WithPrefix())...)
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		item, err := r.decodeKV(kv, readOptions.KeyOnly)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rangeOps completes the options of a range request with the reader ones and the given read options.
+func (r reader) rangeOps(limit store.Limit, readOptions store.ReadOptions, ops ...clientV3.OpOption) []clientV3.OpOption {
+	ops = append(ops, r.ops...)
+
+	if limit.Bounded() {
+		ops = append(ops, clientV3.WithLimit(int64(limit)))
+	}
+
+	if readOptions.KeyOnly {
+		ops = append(ops, clientV3.WithKeysOnly())
+	}
+
+	if readOptions.Reverse {
+		ops = append(ops, clientV3.WithSort(clientV3.SortByKey, clientV3.SortDescend))
+	}
+
+	return ops
+}
+
+// decodeKV decodes the key of a range response entry, and decompresses its value unless `keyOnly`.
+func (r reader) decodeKV(kv *mvccpb.KeyValue, keyOnly bool) (item store.KV, err error) {
+	item.Key, err = decodeKey(kv.Key)
+	if err != nil {
+		return item, err
+	}
+
+	if !keyOnly {
+		item.Value, err = r.compression.Decompress(kv.Value)
+	}
+	return item, err
+}
+
+// encodeRange maps the [start, exclusiveEnd) byte range onto etcd keys, an empty bound
+// being open-ended.
+func encodeRange(start, exclusiveEnd []byte) (key, end string) {
+	key, end = "\x00", "\x00"
+	if len(start) > 0 {
+		key = store.Key(start).String()
+	}
+	if len(exclusiveEnd) > 0 {
+		end = store.Key(exclusiveEnd).String()
+	}
+	return key, end
+}
+
+func decodeKey(key []byte) ([]byte, error) {
+	out := make([]byte, hex.DecodedLen(len(key)))
+	if _, err := hex.Decode(out, key); err != nil {
+		return nil, fmt.Errorf("decode key %q: %w", key, err)
+	}
+	return out, nil
+}
+
 func (s *Store) BatchDelete(ctx context.Context, keys [][]byte) (err error) {
 	log.Debugw("batch deletion", "key_count", len(keys))
 
@@ -255,12 +378,26 @@ func (s *Store) BatchDelete(ctx context.Context, keys [][]byte) (err error) {
 	return
 }
 
+func (s *Store) DeletePrefix(ctx context.Context, prefix []byte) (err error) {
+	log.Debugw("prefix deletion", "prefix", store.Key(prefix))
+	_, err = s.db.KV.Delete(ctx, store.Key(prefix).String(), clientV3.WithPrefix())
+	return err
+}
+
+func (s *Store) DeleteRange(ctx context.Context, start, exclusiveEnd []byte) (err error) {
+	log.Debugw("range deletion", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd))
+	key, end := encodeRange(start, exclusiveEnd)
+	_, err = s.db.KV.Delete(ctx, key, clientV3.WithRange(end))
+	return err
+}
+
 func (s *Store) Delete(ctx context.Context, key []byte) (err error) {
 	_, err = s.db.KV.Delete(ctx, store.Key(key).String())
 	return err
 }
 
 func (s *Store) Close() error {
+	s.flusher.Stop()
 	if err := s.FlushPuts(context.TODO()); err != nil {
 		log.Errorf("flush: %s", err)
 	}
@@ -1,10 +1,14 @@
 package etcd
 
 import (
+	"bytes"
 	"context"
 	"github.com/bitrainforest/kdb/store"
 	"github.com/stretchr/testify/require"
+	clientV3 "go.etcd.io/etcd/client/v3"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func makeStore(t *testing.T) store.Store {
@@ -84,3 +88,449 @@ func TestStore_Batch(t *testing.T) {
 	require.Equal(t, false, it.Next())
 	require.ErrorIs(t, it.Err(), store.ErrNotFound)
 }
+
+func TestStore_Scan(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("height/01"), []byte("height/02"), []byte("height/03"), []byte("height/04")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	it := st.Scan(ctx, keys[1], keys[3], 0)
+	var vv [][]byte
+	for it.Next() {
+		require.Equal(t, it.Item().Key, it.Item().Value)
+		vv = append(vv, it.Item().Key)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, keys[1:3], vv)
+
+	it = st.Scan(ctx, keys[1], keys[3], 1, store.KeyOnly())
+	require.True(t, it.Next())
+	require.Equal(t, keys[1], it.Item().Key)
+	require.Nil(t, it.Item().Value)
+	require.False(t, it.Next())
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_Reverse(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("rev/1"), []byte("rev/2"), []byte("rev/3")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	it := st.Prefix(ctx, []byte("rev/"), 2, store.Reverse())
+	var vv [][]byte
+	for it.Next() {
+		vv = append(vv, it.Item().Key)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, [][]byte{keys[2], keys[1]}, vv)
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_Cursor(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("cursor/a"), []byte("cursor/b"), []byte("cursor/c")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	c, err := st.(store.Cursorer).NewCursor(ctx)
+	require.NoError(t, err)
+
+	require.True(t, c.Seek(keys[1]))
+	require.Equal(t, keys[1], c.Key())
+	require.Equal(t, keys[1], c.Value())
+	require.True(t, c.Prev())
+	require.Equal(t, keys[0], c.Key())
+	require.True(t, c.Next())
+	require.True(t, c.Next())
+	require.Equal(t, keys[2], c.Key())
+	require.NoError(t, c.Err())
+	require.NoError(t, c.Close())
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_Txn(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	tst := st.(store.Transactional)
+
+	key, index := []byte("txn/payload"), []byte("txn/index")
+
+	txn1, err := tst.Begin(ctx)
+	require.NoError(t, err)
+	txn2, err := tst.Begin(ctx)
+	require.NoError(t, err)
+
+	_, err = txn1.Get(ctx, key)
+	require.ErrorIs(t, err, store.ErrNotFound)
+	_, err = txn2.Get(ctx, key)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, txn1.Put(ctx, key, []byte("v1")))
+	require.NoError(t, txn1.Put(ctx, index, key))
+	require.NoError(t, txn2.Put(ctx, key, []byte("v2")))
+
+	require.NoError(t, txn1.Commit(ctx))
+	require.ErrorIs(t, txn2.Commit(ctx), store.ErrConflict)
+
+	err = store.RunTxn(ctx, tst, func(txn store.Txn) error {
+		v, err := txn.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+		return txn.Put(ctx, key, append(v, '!'))
+	})
+	require.NoError(t, err)
+
+	v, err := st.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1!"), v)
+
+	require.NoError(t, st.BatchDelete(ctx, [][]byte{key, index}))
+}
+
+func TestStore_Versioned(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	vst := st.(store.Versioned)
+
+	key := []byte("versioned/voucher")
+
+	require.NoError(t, vst.PutIfAbsent(ctx, key, []byte("v1")))
+	require.ErrorIs(t, vst.PutIfAbsent(ctx, key, []byte("v1")), store.ErrConflict)
+
+	v, version, err := vst.GetVersioned(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	require.NoError(t, vst.CompareAndSwap(ctx, key, version, []byte("v2")))
+	require.ErrorIs(t, vst.CompareAndSwap(ctx, key, version, []byte("v3")), store.ErrConflict)
+
+	require.NoError(t, st.Delete(ctx, key))
+}
+
+func TestStore_TTL(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	key := []byte("ttl/cache")
+
+	require.NoError(t, st.Put(ctx, key, []byte("value"), store.WithTTL(time.Second)))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	_, err := st.Get(ctx, key)
+	require.NoError(t, err)
+
+	time.Sleep(3 * time.Second)
+
+	_, err = st.Get(ctx, key)
+	require.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestStore_Watch(t *testing.T) {
+	st := makeStore(t)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	events := st.(store.Watcher).Watch(ctx, []byte("watch/"))
+
+	key := []byte("watch/a")
+	require.NoError(t, st.Put(ctx, key, []byte("1")))
+	require.NoError(t, st.FlushPuts(ctx))
+	require.NoError(t, st.Delete(ctx, key))
+
+	ev := <-events
+	require.Equal(t, store.EventPut, ev.Type)
+	require.Equal(t, key, ev.Key)
+
+	ev = <-events
+	require.Equal(t, store.EventDelete, ev.Type)
+	require.Equal(t, key, ev.Key)
+}
+
+func TestStore_Incr(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	counter := st.(store.Counter)
+	key := []byte("counter/blocks")
+
+	n, err := counter.Incr(ctx, key, 5)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+
+	n, err = counter.Incr(ctx, key, -2)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	v, err := st.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, []byte("3"), v)
+
+	require.NoError(t, st.Delete(ctx, key))
+}
+
+func TestStore_Count(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("count/1"), []byte("count/2"), []byte("count/3")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	n, err := st.Count(ctx, []byte("count/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_Has(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("has/a"), []byte("has/b"), []byte("has/c")}
+	require.NoError(t, st.Put(ctx, keys[0], []byte("1")))
+	require.NoError(t, st.Put(ctx, keys[2], []byte("3")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	ok, err := st.Has(ctx, keys[0])
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	exists, err := st.BatchHas(ctx, keys)
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false, true}, exists)
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_BatchGetAllowMissing(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("sparse/a"), []byte("sparse/b"), []byte("sparse/c")}
+	require.NoError(t, st.Put(ctx, keys[0], []byte("1")))
+	require.NoError(t, st.Put(ctx, keys[2], []byte("3")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	it := st.BatchGet(ctx, keys, store.AllowMissing())
+	var vv [][]byte
+	for it.Next() {
+		vv = append(vv, it.Item().Value)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, [][]byte{[]byte("1"), nil, []byte("3")}, vv)
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_PrefixPage(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("page/1"), []byte("page/2"), []byte("page/3"), []byte("page/4"), []byte("page/5")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	seen := map[string]bool{}
+	token := ""
+	for {
+		items, next, err := st.PrefixPage(ctx, []byte("page/"), 2, token)
+		require.NoError(t, err)
+		for _, item := range items {
+			require.Equal(t, item.Key, item.Value)
+			seen[string(item.Key)] = true
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	require.Len(t, seen, len(keys))
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("snapshot/a"), []byte("snapshot/b")}
+	require.NoError(t, st.Put(ctx, keys[0], []byte("1")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	sn, err := st.(store.Snapshotter).Snapshot(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, st.Put(ctx, keys[0], []byte("2")))
+	require.NoError(t, st.Put(ctx, keys[1], []byte("2")))
+	require.NoError(t, st.FlushPuts(ctx))
+
+	v, err := sn.Get(ctx, keys[0])
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), v)
+
+	n, err := sn.Count(ctx, []byte("snapshot/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	require.NoError(t, sn.Close())
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_History(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+	key := []byte("history/state")
+
+	for _, v := range []string{"1", "2", "3"} {
+		require.NoError(t, st.Put(ctx, key, []byte(v)))
+		require.NoError(t, st.FlushPuts(ctx))
+	}
+
+	history, err := st.(store.HistoryReader).History(ctx, key, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	require.Equal(t, []byte("3"), history[0].Value)
+	require.Equal(t, []byte("1"), history[2].Value)
+
+	require.NoError(t, st.Delete(ctx, key))
+}
+
+func TestStore_DeletePrefixAndRange(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	for _, k := range []string{"del/a/1", "del/a/2", "del/b/1", "del/b/2", "del/b/3"} {
+		require.NoError(t, st.Put(ctx, []byte(k), []byte(k)))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	require.NoError(t, st.DeletePrefix(ctx, []byte("del/a/")))
+	n, err := st.Count(ctx, []byte("del/"))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	require.NoError(t, st.DeleteRange(ctx, []byte("del/b/2"), []byte("del/c")))
+	exists, err := st.BatchHas(ctx, [][]byte{[]byte("del/b/1"), []byte("del/b/2"), []byte("del/b/3")})
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false, false}, exists)
+
+	require.NoError(t, st.DeletePrefix(ctx, []byte("del/")))
+}
+
+func TestStore_ForEachPrefix(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	keys := [][]byte{[]byte("each/1"), []byte("each/2"), []byte("each/3")}
+	for _, k := range keys {
+		require.NoError(t, st.Put(ctx, k, k))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	var seen [][]byte
+	require.NoError(t, st.ForEachPrefix(ctx, []byte("each/"), func(key, value []byte) error {
+		require.Equal(t, key, value)
+		seen = append(seen, append([]byte(nil), key...))
+		return nil
+	}))
+	require.Equal(t, keys, seen)
+
+	require.NoError(t, st.BatchDelete(ctx, keys))
+}
+
+func TestStore_NewBatch(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	b := st.NewBatch()
+	require.NoError(t, b.Put(ctx, []byte("batch/1"), []byte("1")))
+	require.NoError(t, b.Put(ctx, []byte("batch/1"), []byte("2")))
+	require.NoError(t, b.Put(ctx, []byte("batch/2"), []byte("2")))
+	require.NoError(t, b.Delete(ctx, []byte("batch/2")))
+	require.Equal(t, 4, b.Len())
+	require.NoError(t, b.Commit(ctx))
+	require.Equal(t, 0, b.Len())
+
+	value, err := st.Get(ctx, []byte("batch/1"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), value)
+	exists, err := st.Has(ctx, []byte("batch/2"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, b.Put(ctx, []byte("batch/3"), []byte("3")))
+	b.Discard()
+	exists, err = st.Has(ctx, []byte("batch/3"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, st.Delete(ctx, []byte("batch/1")))
+}
+
+func TestSplitTxn(t *testing.T) {
+	ops := make([]clientV3.Op, 300)
+	sizes := make([]int, len(ops))
+	for i := range sizes {
+		sizes[i] = 16 << 10
+	}
+	sizes[200] = 2 << 20
+
+	var total int
+	for _, txn := range splitTxn(ops, sizes) {
+		require.LessOrEqual(t, len(txn), maxTxnOps)
+		var size int
+		for i := range txn {
+			size += sizes[total+i]
+		}
+		if len(txn) > 1 {
+			require.LessOrEqual(t, size, maxTxnBytes)
+		}
+		total += len(txn)
+	}
+	require.Equal(t, len(ops), total)
+}
+
+func TestStore_NewBatchLarge(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	// 2 MiB in total, more than the request size limit of etcd servers
+	value := bytes.Repeat([]byte("x"), 16<<10)
+	b := st.NewBatch()
+	for i := 0; i < 128; i++ {
+		require.NoError(t, b.Put(ctx, []byte("large/"+strconv.Itoa(i)), value))
+	}
+	require.NoError(t, b.Commit(ctx))
+
+	for i := 0; i < 128; i++ {
+		require.NoError(t, st.Put(ctx, []byte("large/"+strconv.Itoa(i)), value))
+	}
+	require.NoError(t, st.FlushPuts(ctx))
+
+	got, err := st.Get(ctx, []byte("large/127"))
+	require.NoError(t, err)
+	require.Equal(t, value, got)
+
+	require.NoError(t, st.DeletePrefix(ctx, []byte("large/")))
+}
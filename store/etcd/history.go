@@ -0,0 +1,52 @@
+package etcd
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientV3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ store.HistoryReader = (*Store)(nil)
+
+// History walks back the revisions of the key, from the latest one to its creation, or to the last
+// compaction of the cluster.
+func (s *Store) History(ctx context.Context, key []byte, limit int) (history []store.VersionedKV, err error) {
+	log.Debugw("history", "key", store.Key(key), "limit", store.Limit(limit))
+	k := store.Key(key).String()
+
+	var ops []clientV3.OpOption
+	for !store.Limit(limit).Reached(uint64(len(history))) {
+		resp, err := s.db.KV.Get(ctx, k, ops...)
+		if err == rpctypes.ErrCompacted {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Kvs) == 0 {
+			break
+		}
+
+		kv := resp.Kvs[0]
+		value, err := s.compression.Decompress(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, store.VersionedKV{
+			KV:      store.KV{Key: key, Value: value},
+			Version: encodeVersion(kv.ModRevision),
+		})
+
+		// The first version of the key since its creation
+		if kv.Version == 1 {
+			break
+		}
+		ops = []clientV3.OpOption{clientV3.WithRev(kv.ModRevision - 1)}
+	}
+
+	if len(history) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return history, nil
+}
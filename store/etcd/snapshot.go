@@ -0,0 +1,33 @@
+package etcd
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	clientV3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ store.Snapshotter = (*Store)(nil)
+
+// Snapshot pins the current revision of the cluster, which every read of the snapshot is made at.
+// Reads fail once the revision has been compacted.
+func (s *Store) Snapshot(ctx context.Context) (store.Snapshot, error) {
+	resp, err := s.db.KV.Get(ctx, "\x00", clientV3.WithCountOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugw("snapshot", "revision", resp.Header.Revision)
+	return &snapshot{reader: reader{
+		db:          s.db,
+		compression: s.compression,
+		ops:         []clientV3.OpOption{clientV3.WithRev(resp.Header.Revision)},
+	}}, nil
+}
+
+type snapshot struct {
+	reader
+}
+
+func (sn *snapshot) Close() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+package etcd
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	clientV3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ store.Transactional = (*Store)(nil)
+
+func (s *Store) Begin(_ context.Context) (store.Txn, error) {
+	return &txn{
+		s:      s,
+		reads:  make(map[string]int64),
+		writes: make(map[string]*txnWrite),
+	}, nil
+}
+
+// txn records the revision of every key it reads and buffers its writes, committing them in an etcd
+// transaction guarded by the recorded revisions. Note that etcd limits the number of operations in a
+// transaction (`--max-txn-ops`, 128 by default).
+type txn struct {
+	s      *Store
+	reads  map[string]int64 // ModRevision of the keys read, 0 when they did not exist
+	writes map[string]*txnWrite
+	order  []string
+}
+
+type txnWrite struct {
+	value   []byte
+	deleted bool
+}
+
+func (t *txn) Get(ctx context.Context, key []byte) (value []byte, err error) {
+	k := store.Key(key).String()
+	if w, ok := t.writes[k]; ok {
+		if w.deleted {
+			return nil, store.ErrNotFound
+		}
+		return w.value, nil
+	}
+
+	res, err := t.s.db.KV.Get(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	var rev int64
+	if len(res.Kvs) > 0 {
+		rev = res.Kvs[0].ModRevision
+	}
+	if _, ok := t.reads[k]; !ok {
+		t.reads[k] = rev
+	}
+
+	if rev == 0 {
+		return nil, store.ErrNotFound
+	}
+	return t.s.compression.Decompress(res.Kvs[0].Value)
+}
+
+func (t *txn) Put(_ context.Context, key, value []byte) (err error) {
+	t.write(store.Key(key).String(), &txnWrite{value: value})
+	return nil
+}
+
+func (t *txn) Delete(_ context.Context, key []byte) (err error) {
+	t.write(store.Key(key).String(), &txnWrite{deleted: true})
+	return nil
+}
+
+func (t *txn) write(k string, w *txnWrite) {
+	if _, ok := t.writes[k]; !ok {
+		t.order = append(t.order, k)
+	}
+	t.writes[k] = w
+}
+
+func (t *txn) Commit(ctx context.Context) (err error) {
+	if len(t.writes) == 0 {
+		return nil
+	}
+
+	var cmps []clientV3.Cmp
+	for k, rev := range t.reads {
+		cmps = append(cmps, clientV3.Compare(clientV3.ModRevision(k), "=", rev))
+	}
+
+	var ops []clientV3.Op
+	for _, k := range t.order {
+		w := t.writes[k]
+		if w.deleted {
+			ops = append(ops, clientV3.OpDelete(k))
+		} else {
+			ops = append(ops, clientV3.OpPut(k, string(t.s.compression.Compress(w.value))))
+		}
+	}
+
+	resp, err := t.s.db.Txn(ctx).If(cmps...).Then(ops...).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return store.ErrConflict
+	}
+
+	t.writes = nil
+	return nil
+}
+
+func (t *txn) Rollback() (err error) {
+	t.writes = nil
+	return nil
+}
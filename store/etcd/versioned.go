@@ -0,0 +1,71 @@
+package etcd
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	clientV3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ store.Versioned = (*Store)(nil)
+
+// GetVersioned returns the revision at which the key was last modified as its version.
+func (s *Store) GetVersioned(ctx context.Context, key []byte) (value []byte, version store.Version, err error) {
+	log.Debugw("getting versioned", "key", store.Key(key))
+	res, err := s.db.KV.Get(ctx, store.Key(key).String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(res.Kvs) == 0 {
+		return nil, nil, store.ErrNotFound
+	}
+
+	value, err = s.compression.Decompress(res.Kvs[0].Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, encodeVersion(res.Kvs[0].ModRevision), nil
+}
+
+func (s *Store) CompareAndSwap(ctx context.Context, key []byte, expected store.Version, value []byte) (err error) {
+	log.Debugw("compare and swap", "key", store.Key(key))
+	rev, err := decodeVersion(expected)
+	if err != nil {
+		return err
+	}
+
+	k := store.Key(key).String()
+	return s.putIf(ctx, k, value, clientV3.Compare(clientV3.ModRevision(k), "=", rev))
+}
+
+func (s *Store) PutIfAbsent(ctx context.Context, key, value []byte) (err error) {
+	log.Debugw("put if absent", "key", store.Key(key))
+	k := store.Key(key).String()
+	return s.putIf(ctx, k, value, clientV3.Compare(clientV3.CreateRevision(k), "=", 0))
+}
+
+func (s *Store) putIf(ctx context.Context, k string, value []byte, cmp clientV3.Cmp) error {
+	resp, err := s.db.Txn(ctx).If(cmp).Then(clientV3.OpPut(k, string(s.compression.Compress(value)))).Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return store.ErrConflict
+	}
+	return nil
+}
+
+func encodeVersion(rev int64) store.Version {
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, uint64(rev))
+	return out
+}
+
+func decodeVersion(version store.Version) (int64, error) {
+	if len(version) != 8 {
+		return 0, fmt.Errorf("invalid version %x", []byte(version))
+	}
+	return int64(binary.BigEndian.Uint64(version)), nil
+}
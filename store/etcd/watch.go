@@ -0,0 +1,61 @@
+package etcd
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	clientV3 "go.etcd.io/etcd/client/v3"
+)
+
+var _ store.Watcher = (*Store)(nil)
+
+func (s *Store) Watch(ctx context.Context, prefix []byte) <-chan store.Event {
+	log.Debugw("watching", "prefix", store.Key(prefix))
+	events := make(chan store.Event, 100)
+
+	// The watch stream is opened in the background, so it starts from the current revision in order not
+	// to miss the changes made in the meantime
+	key := store.Key(prefix).String()
+	head, err := s.db.Get(ctx, key, clientV3.WithPrefix(), clientV3.WithCountOnly())
+	if err != nil {
+		log.Errorf("watch %s: %s", store.Key(prefix), err)
+		close(events)
+		return events
+	}
+	watch := s.db.Watch(ctx, key, clientV3.WithPrefix(), clientV3.WithRev(head.Header.Revision+1))
+
+	go func() {
+		defer close(events)
+		for resp := range watch {
+			if err := resp.Err(); err != nil {
+				log.Errorf("watch %s: %s", store.Key(prefix), err)
+				return
+			}
+
+			for _, e := range resp.Events {
+				key, err := decodeKey(e.Kv.Key)
+				if err != nil {
+					log.Errorf("watch %s: %s", store.Key(prefix), err)
+					return
+				}
+
+				ev := store.Event{Type: store.EventDelete, Key: key, Revision: e.Kv.ModRevision}
+				if e.Type == clientV3.EventTypePut {
+					ev.Type = store.EventPut
+					ev.Value, err = s.compression.Decompress(e.Kv.Value)
+					if err != nil {
+						log.Errorf("watch %s: %s", store.Key(prefix), err)
+						return
+					}
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case events <- ev:
+				}
+			}
+		}
+	}()
+
+	return events
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BatchPolicy tells when the pending writes of `Put` are flushed, besides calls to `FlushPuts`.
+type BatchPolicy struct {
+	// Size flushes once that many writes are pending, 0 for no limit.
+	Size int
+	// Bytes flushes once the pending keys and values reach that size in bytes, 0 for no limit.
+	Bytes int
+	// FlushInterval flushes in the background at that interval, 0 to never flush in the background.
+	FlushInterval time.Duration
+}
+
+// ParseBatchPolicy reads a BatchPolicy from the `batch_size`, `batch_bytes` and `flush_interval` DSN
+// parameters, which are removed from query.  The fields of defaults are kept for the missing
+// parameters, so that an explicit 0 still means no limit.
+func ParseBatchPolicy(query url.Values, defaults BatchPolicy) (policy BatchPolicy, err error) {
+	policy = defaults
+
+	if query.Has("batch_size") {
+		policy.Size, err = strconv.Atoi(query.Get("batch_size"))
+		if err != nil || policy.Size < 0 {
+			return policy, fmt.Errorf("invalid batch size %q", query.Get("batch_size"))
+		}
+		query.Del("batch_size")
+	}
+
+	if query.Has("batch_bytes") {
+		policy.Bytes, err = strconv.Atoi(query.Get("batch_bytes"))
+		if err != nil || policy.Bytes < 0 {
+			return policy, fmt.Errorf("invalid batch bytes %q", query.Get("batch_bytes"))
+		}
+		query.Del("batch_bytes")
+	}
+
+	if query.Has("flush_interval") {
+		policy.FlushInterval, err = time.ParseDuration(query.Get("flush_interval"))
+		if err != nil || policy.FlushInterval < 0 {
+			return policy, fmt.Errorf("invalid flush interval %q", query.Get("flush_interval"))
+		}
+		query.Del("flush_interval")
+	}
+	return policy, nil
+}
+
+// Full tells whether a batch holding the pending writes must be flushed.
+func (p BatchPolicy) Full(b Batch) bool {
+	return (p.Size > 0 && b.Len() >= p.Size) || (p.Bytes > 0 && b.Size() >= p.Bytes)
+}
+
+// AutoFlusher calls a flush function in the background, at a fixed interval.  A failure is given to
+// the error handler when one is set, otherwise it is kept until `Err` is called.
+//
+// The methods of a nil AutoFlusher are no-ops, standing for a store which does not flush in the
+// background.
+type AutoFlusher struct {
+	flush func(ctx context.Context) error
+
+	stop chan struct{}
+	done chan struct{}
+
+	lk      sync.Mutex
+	err     error
+	onError func(err error)
+}
+
+// NewAutoFlusher starts calling flush every interval, returning nil when interval is 0.
+func NewAutoFlusher(interval time.Duration, flush func(ctx context.Context) error) *AutoFlusher {
+	if interval <= 0 {
+		return nil
+	}
+
+	f := &AutoFlusher{
+		flush: flush,
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go f.run(interval)
+	return f
+}
+
+func (f *AutoFlusher) run(interval time.Duration) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := f.flush(context.Background()); err != nil {
+			f.fail(err)
+		}
+	}
+}
+
+func (f *AutoFlusher) fail(err error) {
+	f.lk.Lock()
+	onError := f.onError
+	if onError == nil {
+		f.err = err
+	}
+	f.lk.Unlock()
+
+	if onError != nil {
+		onError(err)
+	}
+}
+
+// SetErrorHandler makes the background failures be given to fn instead of being kept for `Err`.
+func (f *AutoFlusher) SetErrorHandler(fn func(err error)) {
+	if f == nil {
+		return
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.onError = fn
+}
+
+// Err returns the last background failure since the previous call, if any.
+func (f *AutoFlusher) Err() error {
+	if f == nil {
+		return nil
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	err := f.err
+	f.err = nil
+	if err != nil {
+		return fmt.Errorf("background flush: %w", err)
+	}
+	return nil
+}
+
+// Stop stops flushing in the background, waiting for a flush in progress to end.
+func (f *AutoFlusher) Stop() {
+	if f == nil {
+		return
+	}
+
+	select {
+	case <-f.stop:
+	default:
+		close(f.stop)
+	}
+	<-f.done
+}
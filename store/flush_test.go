@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseBatchPolicy(t *testing.T) {
+	query := url.Values{
+		"batch_size":     {"10"},
+		"batch_bytes":    {"4096"},
+		"flush_interval": {"2s"},
+		"compression":    {"zstd"},
+	}
+	policy, err := ParseBatchPolicy(query, BatchPolicy{})
+	require.NoError(t, err)
+	require.Equal(t, BatchPolicy{Size: 10, Bytes: 4096, FlushInterval: 2 * time.Second}, policy)
+	require.Equal(t, url.Values{"compression": {"zstd"}}, query)
+
+	// Defaults are kept for the missing parameters only
+	policy, err = ParseBatchPolicy(url.Values{"batch_size": {"0"}}, BatchPolicy{Size: 500, Bytes: 4096})
+	require.NoError(t, err)
+	require.Equal(t, BatchPolicy{Size: 0, Bytes: 4096}, policy)
+
+	_, err = ParseBatchPolicy(url.Values{"flush_interval": {"-1s"}}, BatchPolicy{})
+	require.Error(t, err)
+}
+
+func TestAutoFlusher(t *testing.T) {
+	require.Nil(t, NewAutoFlusher(0, nil))
+
+	failure := errors.New("unavailable")
+	flushed := make(chan struct{}, 1)
+	f := NewAutoFlusher(10*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case flushed <- struct{}{}:
+		default:
+		}
+		return failure
+	})
+	<-flushed
+	require.Eventually(t, func() bool {
+		err := f.Err()
+		return errors.Is(err, failure)
+	}, time.Second, 5*time.Millisecond)
+
+	handled := make(chan error, 1)
+	f.SetErrorHandler(func(err error) {
+		select {
+		case handled <- err:
+		default:
+		}
+	})
+	require.ErrorIs(t, <-handled, failure)
+	f.Stop()
+	f.Stop()
+}
@@ -0,0 +1,17 @@
+package store
+
+import "context"
+
+// VersionedKV is a value held by a key at some version.
+type VersionedKV struct {
+	KV
+	Version Version
+}
+
+// HistoryReader is implemented by stores keeping the past values of keys.
+type HistoryReader interface {
+	// History returns the values held by key, most recent first, stopping after `limit` entries when
+	// `limit` is greater than 0.  Deletions are not reported, and the history starts over after them.
+	// Returns `ErrNotFound` if the key holds no value.
+	History(ctx context.Context, key []byte, limit int) (history []VersionedKV, err error)
+}
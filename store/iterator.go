@@ -12,6 +12,7 @@ import (
 //    Next() is called by consumer until items channel is empty
 // 3. The context given by the consumer is cancelled, notifying
 //    the db backend and (hopefully) causing a PushError() to be called with context.Canceled
+// 4. Close() is called by the consumer, causing the following PushItem() to return false
 //
 // In any of these cases, the following call to Next() returns false.
 //
@@ -21,7 +22,8 @@ import (
 // * No other Push...() function is called PushFinished() or PushError().
 // * Next(), Item() and Error() are never called concurrently.
 // * PushItem(), PushFinished() and PushError() are never called concurrently.
-// * If the reader wants to finish early, it should close the context to prevent waste
+// * If the reader wants to finish early, it should call Close() to prevent waste
+// * The db backend stops producing once PushItem() returned false
 //
 type Iterator struct {
 	ctx      context.Context
@@ -30,6 +32,9 @@ type Iterator struct {
 	lastItem KV
 	err      error
 	once     sync.Once
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // NewIterator provides a streaming result set for key/value queries
@@ -38,6 +43,7 @@ func NewIterator(ctx context.Context) *Iterator {
 		ctx:     ctx,
 		items:   make(chan KV, 100),
 		errorCh: make(chan error, 1),
+		done:    make(chan struct{}),
 	}
 }
 
@@ -46,6 +52,12 @@ func (it *Iterator) Next() bool {
 		return false
 	}
 
+	select {
+	case <-it.done:
+		return false
+	default:
+	}
+
 	select {
 	case val, ok := <-it.items:
 		if !ok {
@@ -69,9 +81,57 @@ func (it *Iterator) Err() error {
 	return it.err
 }
 
-// PushItem adds an item to the iterator
+// Close stops the iterator: the following calls to Next() return false, and the db backend is
+// notified to stop producing items.  Close can be called at any time, more than once.
+func (it *Iterator) Close() {
+	it.closeOnce.Do(func() {
+		close(it.done)
+	})
+}
+
+// All returns a function iterating over the items, for use with range-over-func loops.  A failure is
+// yielded last, along with an empty KV.  The iterator is closed once the loop ends, even early.
+func (it *Iterator) All() func(yield func(KV, error) bool) {
+	return func(yield func(KV, error) bool) {
+		defer it.Close()
+		for it.Next() {
+			if !yield(it.Item(), nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(KV{}, err)
+		}
+	}
+}
+
+// Collect reads every remaining item, and closes the iterator.
+func (it *Iterator) Collect() (items []KV, err error) {
+	defer it.Close()
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
+
+// ForEach calls fn on every item of the iterator until it fails, and closes the iterator.
+func ForEach(it *Iterator, fn func(key, value []byte) error) error {
+	defer it.Close()
+	for it.Next() {
+		item := it.Item()
+		if err := fn(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// PushItem adds an item to the iterator.  Returns false when the iterator was closed or its context
+// cancelled, in which case the db backend must stop producing.
 func (it *Iterator) PushItem(res KV) bool {
 	select {
+	case <-it.done:
+		return false
 	case <-it.ctx.Done():
 		it.PushError(it.ctx.Err())
 		return false
@@ -92,3 +152,28 @@ func (it *Iterator) PushError(err error) {
 		close(it.errorCh)
 	})
 }
+
+// mapIterator returns an Iterator yielding the items of it transformed by fn, failing with the first error fn
+// returns.
+func mapIterator(ctx context.Context, it *Iterator, fn func(KV) (KV, error)) *Iterator {
+	out := NewIterator(ctx)
+	go func() {
+		defer it.Close()
+		for it.Next() {
+			item, err := fn(it.Item())
+			if err != nil {
+				out.PushError(err)
+				return
+			}
+			if !out.PushItem(item) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			out.PushError(err)
+			return
+		}
+		out.PushFinished()
+	}()
+	return out
+}
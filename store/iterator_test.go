@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// produce pushes n items to a new iterator from a goroutine, and tells on the returned channel how
+// many were accepted once it stops.
+func produce(n int) (*Iterator, <-chan int) {
+	it := NewIterator(context.Background())
+	pushed := make(chan int, 1)
+	go func() {
+		defer it.PushFinished()
+		for i := 0; i < n; i++ {
+			if !it.PushItem(KV{Key: []byte(strconv.Itoa(i))}) {
+				pushed <- i
+				return
+			}
+		}
+		pushed <- n
+	}()
+	return it, pushed
+}
+
+func TestIterator_Close(t *testing.T) {
+	it, pushed := produce(1000)
+	require.True(t, it.Next())
+	it.Close()
+	require.False(t, it.Next())
+	require.NoError(t, it.Err())
+
+	select {
+	case n := <-pushed:
+		require.Less(t, n, 1000)
+	case <-time.After(time.Second):
+		t.Fatal("producer still blocked after Close")
+	}
+
+	it.Close()
+}
+
+func TestIterator_All(t *testing.T) {
+	it, pushed := produce(1000)
+	var keys []string
+	it.All()(func(kv KV, err error) bool {
+		require.NoError(t, err)
+		keys = append(keys, string(kv.Key))
+		return len(keys) < 3
+	})
+	require.Equal(t, []string{"0", "1", "2"}, keys)
+	require.Less(t, <-pushed, 1000)
+
+	failed := errors.New("failed")
+	it = NewIterator(context.Background())
+	it.PushError(failed)
+	var errs []error
+	it.All()(func(kv KV, err error) bool {
+		errs = append(errs, err)
+		return true
+	})
+	require.Equal(t, []error{failed}, errs)
+}
+
+func TestIterator_Collect(t *testing.T) {
+	it, pushed := produce(250)
+	items, err := it.Collect()
+	require.NoError(t, err)
+	require.Len(t, items, 250)
+	require.Equal(t, 250, <-pushed)
+}
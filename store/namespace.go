@@ -0,0 +1,136 @@
+package store
+
+import "context"
+
+// namespaceStore prefixes the keys of a Store with a namespace.
+type namespaceStore struct {
+	st Store
+	ns []byte
+}
+
+var _ Store = (*namespaceStore)(nil)
+
+// WithNamespace returns a Store writing the keys of st under namespace: keys are prefixed with it when
+// written or read, and it is stripped from the keys yielded back. Several namespaces can share a store,
+// each seeing only its own keys.
+//
+// The namespace shares the write batch and the lifetime of st: `FlushPuts` flushes the pending writes
+// of every namespace, and `Close` closes st. The optional interfaces of st (`Transactional`,
+// `Watcher`...) are not available through the namespace.
+func WithNamespace(st Store, namespace string) Store {
+	return &namespaceStore{st: st, ns: []byte(namespace)}
+}
+
+func (n *namespaceStore) key(key []byte) []byte {
+	out := make([]byte, 0, len(n.ns)+len(key))
+	return append(append(out, n.ns...), key...)
+}
+
+func (n *namespaceStore) keys(keys [][]byte) [][]byte {
+	out := make([][]byte, len(keys))
+	for i, key := range keys {
+		out[i] = n.key(key)
+	}
+	return out
+}
+
+// end prefixes an exclusive range end, an empty one meaning the end of the namespace.
+func (n *namespaceStore) end(exclusiveEnd []byte) []byte {
+	if len(exclusiveEnd) == 0 {
+		return PrefixEnd(n.ns)
+	}
+	return n.key(exclusiveEnd)
+}
+
+func (n *namespaceStore) strip(kv KV) (KV, error) {
+	kv.Key = kv.Key[len(n.ns):]
+	return kv, nil
+}
+
+func (n *namespaceStore) Put(ctx context.Context, key, value []byte, options ...WriteOption) (err error) {
+	return n.st.Put(ctx, n.key(key), value, options...)
+}
+
+func (n *namespaceStore) FlushPuts(ctx context.Context) (err error) {
+	return n.st.FlushPuts(ctx)
+}
+
+func (n *namespaceStore) NewBatch() Batch {
+	return &namespaceBatch{Batch: n.st.NewBatch(), n: n}
+}
+
+// namespaceBatch prefixes the keys written to a batch of the underlying store.
+type namespaceBatch struct {
+	Batch
+	n *namespaceStore
+}
+
+func (b *namespaceBatch) Put(ctx context.Context, key, value []byte, options ...WriteOption) (err error) {
+	return b.Batch.Put(ctx, b.n.key(key), value, options...)
+}
+
+func (b *namespaceBatch) Delete(ctx context.Context, key []byte) (err error) {
+	return b.Batch.Delete(ctx, b.n.key(key))
+}
+
+func (n *namespaceStore) Get(ctx context.Context, key []byte) (value []byte, err error) {
+	return n.st.Get(ctx, n.key(key))
+}
+
+func (n *namespaceStore) Has(ctx context.Context, key []byte) (exists bool, err error) {
+	return n.st.Has(ctx, n.key(key))
+}
+
+func (n *namespaceStore) BatchHas(ctx context.Context, keys [][]byte) (exists []bool, err error) {
+	return n.st.BatchHas(ctx, n.keys(keys))
+}
+
+func (n *namespaceStore) BatchGet(ctx context.Context, keys [][]byte, options ...ReadOption) *Iterator {
+	return mapIterator(ctx, n.st.BatchGet(ctx, n.keys(keys), options...), n.strip)
+}
+
+func (n *namespaceStore) Prefix(ctx context.Context, prefix []byte, limit int, options ...ReadOption) *Iterator {
+	return mapIterator(ctx, n.st.Prefix(ctx, n.key(prefix), limit, options...), n.strip)
+}
+
+func (n *namespaceStore) ForEachPrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error, options ...ReadOption) (err error) {
+	return n.st.ForEachPrefix(ctx, n.key(prefix), func(key, value []byte) error {
+		return fn(key[len(n.ns):], value)
+	}, options...)
+}
+
+func (n *namespaceStore) Count(ctx context.Context, prefix []byte) (count int64, err error) {
+	return n.st.Count(ctx, n.key(prefix))
+}
+
+func (n *namespaceStore) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...ReadOption) *Iterator {
+	return mapIterator(ctx, n.st.Scan(ctx, n.key(start), n.end(exclusiveEnd), limit, options...), n.strip)
+}
+
+func (n *namespaceStore) PrefixPage(ctx context.Context, prefix []byte, pageSize int, token string, options ...ReadOption) (items []KV, next string, err error) {
+	items, next, err = n.st.PrefixPage(ctx, n.key(prefix), pageSize, token, options...)
+	for i := range items {
+		items[i].Key = items[i].Key[len(n.ns):]
+	}
+	return items, next, err
+}
+
+func (n *namespaceStore) Delete(ctx context.Context, key []byte) (err error) {
+	return n.st.Delete(ctx, n.key(key))
+}
+
+func (n *namespaceStore) BatchDelete(ctx context.Context, keys [][]byte) (err error) {
+	return n.st.BatchDelete(ctx, n.keys(keys))
+}
+
+func (n *namespaceStore) DeletePrefix(ctx context.Context, prefix []byte) (err error) {
+	return n.st.DeletePrefix(ctx, n.key(prefix))
+}
+
+func (n *namespaceStore) DeleteRange(ctx context.Context, start, exclusiveEnd []byte) (err error) {
+	return n.st.DeleteRange(ctx, n.key(start), n.end(exclusiveEnd))
+}
+
+func (n *namespaceStore) Close() error {
+	return n.st.Close()
+}
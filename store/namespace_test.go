@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/bitrainforest/kdb/store/badger"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+)
+
+// makeStore opens a badger store for the wrappers to wrap.
+func makeStore(t *testing.T) store.Store {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "badger")
+	require.NoError(t, err)
+	st, err := badger.NewStore(dir)
+	require.NoError(t, err)
+	return st
+}
+
+func TestWithNamespace(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	a := store.WithNamespace(st, "tenantA/")
+	b := store.WithNamespace(st, "tenantB/")
+	for _, k := range []string{"k1", "k2", "k3"} {
+		require.NoError(t, a.Put(ctx, []byte(k), []byte("a-"+k)))
+	}
+	require.NoError(t, b.Put(ctx, []byte("k1"), []byte("b-k1")))
+	require.NoError(t, a.FlushPuts(ctx))
+
+	value, err := st.Get(ctx, []byte("tenantA/k1"))
+	require.NoError(t, err)
+	require.Equal(t, "a-k1", string(value))
+	value, err = b.Get(ctx, []byte("k1"))
+	require.NoError(t, err)
+	require.Equal(t, "b-k1", string(value))
+	_, err = b.Get(ctx, []byte("k2"))
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	var keys []string
+	it := a.Prefix(ctx, []byte("k"), 0)
+	for it.Next() {
+		keys = append(keys, string(it.Item().Key))
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"k1", "k2", "k3"}, keys)
+
+	keys = nil
+	it = a.Scan(ctx, []byte("k2"), nil, 0)
+	for it.Next() {
+		keys = append(keys, string(it.Item().Key))
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []string{"k2", "k3"}, keys)
+
+	it = b.BatchGet(ctx, [][]byte{[]byte("k1")})
+	require.True(t, it.Next())
+	require.Equal(t, store.KV{Key: []byte("k1"), Value: []byte("b-k1")}, it.Item())
+	require.False(t, it.Next())
+	require.NoError(t, it.Err())
+
+	items, next, err := a.PrefixPage(ctx, nil, 2, "")
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	require.Equal(t, "k1", string(items[0].Key))
+	items, next, err = a.PrefixPage(ctx, nil, 2, next)
+	require.NoError(t, err)
+	require.Equal(t, []store.KV{{Key: []byte("k3"), Value: []byte("a-k3")}}, items)
+	require.Empty(t, next)
+
+	require.NoError(t, a.DeleteRange(ctx, []byte("k2"), nil))
+	n, err := a.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	n, err = b.Count(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	require.NoError(t, st.Close())
+}
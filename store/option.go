@@ -1,6 +1,9 @@
 package store
 
-import "go.uber.org/zap/zapcore"
+import (
+	"go.uber.org/zap/zapcore"
+	"time"
+)
 
 type EmptyValueEnabler interface {
 	EnableEmpty()
@@ -23,6 +26,25 @@ func (e emptyValueOpt) Apply(s Store) {
 	}
 }
 
+// FlushErrorNotifier is implemented by stores flushing their pending writes in the background.
+type FlushErrorNotifier interface {
+	OnFlushError(fn func(err error))
+}
+
+type flushErrorHandlerOpt func(err error)
+
+// WithFlushErrorHandler makes the failures of background flushes be given to fn.  Without a handler,
+// a failure is returned by the next call to `Put` or `FlushPuts`.
+func WithFlushErrorHandler(fn func(err error)) Option {
+	return flushErrorHandlerOpt(fn)
+}
+
+func (o flushErrorHandlerOpt) Apply(s Store) {
+	if n, ok := s.(FlushErrorNotifier); ok {
+		n.OnFlushError(o)
+	}
+}
+
 func NewReadOptions(opts ...ReadOption) (out *ReadOptions) {
 	if len(opts) == 0 {
 		return nil
@@ -37,16 +59,22 @@ func NewReadOptions(opts ...ReadOption) (out *ReadOptions) {
 }
 
 type ReadOptions struct {
-	KeyOnly bool
+	KeyOnly      bool
+	Reverse      bool
+	AllowMissing bool
 }
 
 func (o *ReadOptions) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
 	if o == nil {
 		encoder.AddBool("key_only", false)
+		encoder.AddBool("reverse", false)
+		encoder.AddBool("allow_missing", false)
 		return nil
 	}
 
 	encoder.AddBool("key_only", o.KeyOnly)
+	encoder.AddBool("reverse", o.Reverse)
+	encoder.AddBool("allow_missing", o.AllowMissing)
 	return nil
 }
 
@@ -63,3 +91,72 @@ type keyOnlyReadOption struct{}
 func (o keyOnlyReadOption) Apply(opts *ReadOptions) {
 	opts.KeyOnly = true
 }
+
+// Reverse walks `Prefix` and `Scan` results in descending key order.
+func Reverse() ReadOption {
+	return reverseReadOption{}
+}
+
+type reverseReadOption struct{}
+
+func (o reverseReadOption) Apply(opts *ReadOptions) {
+	opts.Reverse = true
+}
+
+// AllowMissing makes `BatchGet` yield missing keys with a nil `Value` instead of failing with
+// `ErrNotFound`. An empty value cannot be told apart from a missing key in this mode.
+func AllowMissing() ReadOption {
+	return allowMissingReadOption{}
+}
+
+type allowMissingReadOption struct{}
+
+func (o allowMissingReadOption) Apply(opts *ReadOptions) {
+	opts.AllowMissing = true
+}
+
+type WriteOptions struct {
+	// TTL makes the key expire after the given duration, keys never expire when 0.
+	TTL time.Duration
+	// Sync makes `FlushPuts` wait for the write to be durable.
+	Sync bool
+}
+
+func (o *WriteOptions) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	if o == nil {
+		encoder.AddDuration("ttl", 0)
+		encoder.AddBool("sync", false)
+		return nil
+	}
+
+	encoder.AddDuration("ttl", o.TTL)
+	encoder.AddBool("sync", o.Sync)
+	return nil
+}
+
+type WriteOption interface {
+	Apply(o *WriteOptions)
+}
+
+// WithTTL makes the written key expire after ttl.
+func WithTTL(ttl time.Duration) WriteOption {
+	return ttlWriteOption(ttl)
+}
+
+type ttlWriteOption time.Duration
+
+func (o ttlWriteOption) Apply(opts *WriteOptions) {
+	opts.TTL = time.Duration(o)
+}
+
+// Sync makes the write durable once flushed: `FlushPuts` returns after the write is synced to disk,
+// or acknowledged by replicas, depending on the backend.
+func Sync() WriteOption {
+	return syncWriteOption{}
+}
+
+type syncWriteOption struct{}
+
+func (o syncWriteOption) Apply(opts *WriteOptions) {
+	opts.Sync = true
+}
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+// ScanPage implements `Store.PrefixPage` on top of `Scan`, for stores keeping their keys ordered. The
+// continuation token is the hex encoded last key of the page, and the `Reverse()` option is honored.
+func ScanPage(ctx context.Context, st Store, prefix []byte, pageSize int, token string, options ...ReadOption) (items []KV, next string, err error) {
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	readOptions := ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
+	start, end := prefix, PrefixEnd(prefix)
+	if token != "" {
+		last, err := hex.DecodeString(token)
+		if err != nil || !bytes.HasPrefix(last, prefix) {
+			return nil, "", fmt.Errorf("invalid continuation token %q", token)
+		}
+
+		if readOptions.Reverse {
+			end = last
+		} else {
+			// the smallest key after the last one
+			start = append(last, 0)
+		}
+	}
+
+	// One more item than the page is fetched to know whether there is a next page
+	it := st.Scan(ctx, start, end, pageSize+1, options...)
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	if err := it.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(items) > pageSize {
+		items = items[:pageSize]
+		next = hex.EncodeToString(items[pageSize-1].Key)
+	}
+	return items, next, nil
+}
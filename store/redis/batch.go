@@ -0,0 +1,108 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	"time"
+)
+
+// NewBatch returns a batch holding its writes until Commit, which executes them in a MULTI/EXEC
+// pipeline on a connection of its own, so that WAIT acknowledges them.  A batch is applied atomically.
+func (s *Store) NewBatch() store.Batch {
+	return s.newBatch()
+}
+
+func (s *Store) newBatch() *batch {
+	return &batch{s: s}
+}
+
+type batch struct {
+	s *Store
+
+	writes []pendingWrite
+	size   int
+	// sync tells whether a write asked for `Sync()`
+	sync bool
+}
+
+type pendingWrite struct {
+	key     string
+	value   []byte
+	ttl     time.Duration
+	deleted bool
+}
+
+func (b *batch) Put(_ context.Context, key, value []byte, options ...store.WriteOption) (err error) {
+	writeOptions := store.WriteOptions{}
+	for _, opt := range options {
+		opt.Apply(&writeOptions)
+	}
+
+	b.writes = append(b.writes, pendingWrite{
+		key:   store.Key(key).String(),
+		value: b.s.compression.Compress(value),
+		ttl:   writeOptions.TTL,
+	})
+	b.size += len(key) + len(value)
+	b.sync = b.sync || writeOptions.Sync
+	return nil
+}
+
+func (b *batch) Delete(_ context.Context, key []byte) (err error) {
+	b.writes = append(b.writes, pendingWrite{key: store.Key(key).String(), deleted: true})
+	b.size += len(key)
+	return nil
+}
+
+func (b *batch) Len() int {
+	return len(b.writes)
+}
+
+func (b *batch) Size() int {
+	return b.size
+}
+
+// Commit executes the pipeline. When one of its writes asked for `Sync()`, it then waits for
+// `wait_replicas` replicas to acknowledge them, failing with `kdb.ErrNotDurable` if they do not within
+// `wait_timeout`. Without replicas to wait for, durability depends on the `appendfsync` policy of the
+// server.
+//
+// Commit keeps the writes when it fails, so that it can be retried.
+func (b *batch) Commit(ctx context.Context) (err error) {
+	if len(b.writes) == 0 {
+		return nil
+	}
+
+	conn := b.s.db.Conn(ctx)
+	defer conn.Close()
+
+	pipe := conn.TxPipeline()
+	for _, w := range b.writes {
+		if w.deleted {
+			pipe.Del(ctx, w.key)
+		} else {
+			pipe.Set(ctx, w.key, w.value, w.ttl)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("batch exec: %w", err)
+	}
+
+	if b.sync && b.s.waitReplicas > 0 {
+		acked, err := conn.Wait(ctx, b.s.waitReplicas, b.s.waitTimeout).Result()
+		if err != nil {
+			return fmt.Errorf("wait: %w", warpRedisError(err))
+		}
+		if acked < int64(b.s.waitReplicas) {
+			return fmt.Errorf("wait: %d of %d replicas acknowledged: %w", acked, b.s.waitReplicas, store.ErrNotDurable)
+		}
+	}
+
+	b.writes, b.size, b.sync = nil, 0, false
+	return nil
+}
+
+func (b *batch) Discard() {
+	b.writes, b.size, b.sync = nil, 0, false
+}
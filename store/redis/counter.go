@@ -0,0 +1,19 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+)
+
+var _ store.Counter = (*Store)(nil)
+
+// Incr uses INCRBY, which fails on a value that is not an integer, such as a compressed one.
+func (s *Store) Incr(ctx context.Context, key []byte, delta int64) (int64, error) {
+	log.Debugw("incrementing", "key", store.Key(key), "delta", delta)
+	value, err := s.db.IncrBy(ctx, store.Key(key).String(), delta).Result()
+	if err != nil {
+		return 0, fmt.Errorf("incr: %w", err)
+	}
+	return value, nil
+}
@@ -2,14 +2,21 @@ package redis
 
 import (
 	"fmt"
+	"github.com/bitrainforest/kdb/store"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 type dsn struct {
 	url         string // https://github.com/lettuce-io/lettuce-core/wiki/Redis-URI-and-connection-details
 	compression string // none, zstd
 	threshold   int    // compression threshold in bytes
+
+	waitReplicas int           // replicas which must acknowledge `Sync()` writes
+	waitTimeout  time.Duration // how long to wait for the replicas, `defaultWaitTimeout` when 0
+
+	batch store.BatchPolicy // when to flush the pending writes
 }
 
 func newDSN(dsnString string) (*dsn, error) {
@@ -42,6 +49,30 @@ func newDSN(dsnString string) (*dsn, error) {
 		query.Del("threshold")
 	}
 
+	if query.Has("wait_replicas") {
+		replicas := query.Get("wait_replicas")
+		i, err := strconv.Atoi(replicas)
+		if err != nil || i < 0 {
+			return nil, fmt.Errorf("invalid wait replicas %q", replicas)
+		}
+		d.waitReplicas = i
+		query.Del("wait_replicas")
+	}
+
+	if query.Has("wait_timeout") {
+		timeout, err := time.ParseDuration(query.Get("wait_timeout"))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid wait timeout %q", query.Get("wait_timeout"))
+		}
+		d.waitTimeout = timeout
+		query.Del("wait_timeout")
+	}
+
+	d.batch, err = store.ParseBatchPolicy(query, defaultBatchPolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	u.RawQuery = query.Encode()
 
 	d.url = u.String()
@@ -2,25 +2,40 @@ package redis
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"github.com/bitrainforest/kdb/store"
 	"github.com/go-redis/redis/v8"
 	logging "github.com/ipfs/go-log"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
 const (
-	maxBatchLen = 500
+	maxBatchLen        = 500
+	defaultWaitTimeout = 5 * time.Second
 )
 
 var log = logging.Logger("kdb/redis")
 
+// defaultBatchPolicy flushes the pending writes every `maxBatchLen` writes, unless the DSN says otherwise.
+var defaultBatchPolicy = store.BatchPolicy{Size: maxBatchLen}
+
 type Store struct {
 	dsn         string
 	db          *redis.Client
 	compression store.Compressor
-	writeBatch  redis.Pipeliner
+	writeBatch  *batch
 	writeLk     sync.Mutex
+
+	// waitReplicas must acknowledge the writes asking for `Sync()`, within waitTimeout
+	waitReplicas int
+	waitTimeout  time.Duration
+
+	policy  store.BatchPolicy
+	flusher *store.AutoFlusher
 }
 
 func NewStore(dsnString string) (store.Store, error) {
@@ -48,44 +63,65 @@ func NewStore(dsnString string) (store.Store, error) {
 		return nil, err
 	}
 
-	return &Store{
-		dsn:         dsnString,
-		db:          client,
-		compression: compression,
-	}, nil
+	waitTimeout := dsn.waitTimeout
+	if waitTimeout == 0 {
+		waitTimeout = defaultWaitTimeout
+	}
+
+	s := &Store{
+		dsn:          dsnString,
+		db:           client,
+		compression:  compression,
+		waitReplicas: dsn.waitReplicas,
+		waitTimeout:  waitTimeout,
+		policy:       dsn.batch,
+	}
+	s.flusher = store.NewAutoFlusher(dsn.batch.FlushInterval, s.flush)
+	return s, nil
 }
 
-func (s *Store) Put(ctx context.Context, key, value []byte) (err error) {
+var _ store.FlushErrorNotifier = (*Store)(nil)
+
+func (s *Store) OnFlushError(fn func(err error)) {
+	s.flusher.SetErrorHandler(fn)
+}
+
+func (s *Store) Put(ctx context.Context, key, value []byte, options ...store.WriteOption) (err error) {
 	log.Debugw("putting", "key", store.Key(key))
+	s.writeLk.Lock()
+	defer s.writeLk.Unlock()
+
 	if s.writeBatch == nil {
-		s.writeBatch = s.db.TxPipeline()
+		s.writeBatch = s.newBatch()
 	}
-	err = s.writeBatch.Set(ctx, store.Key(key).String(), s.compression.Compress(value), 0).Err()
-
-	if err != nil {
-		return fmt.Errorf("set entry: %w", err)
+	if err := s.writeBatch.Put(ctx, key, value, options...); err != nil {
+		return err
 	}
 
-	if s.writeBatch.Len() >= maxBatchLen {
-		_, err = s.writeBatch.Exec(ctx)
-		if err != nil {
-			return fmt.Errorf("batch exec: %w", err)
+	if s.policy.Full(s.writeBatch) {
+		if err := s.writeBatch.Commit(ctx); err != nil {
+			return err
 		}
-		s.writeBatch = s.db.TxPipeline()
 	}
-	return nil
+	return s.flusher.Err()
 }
 
+// FlushPuts executes the pending writes, see `batch.Commit` for the writes asking for `Sync()`.
 func (s *Store) FlushPuts(ctx context.Context) (err error) {
+	if err := s.flush(ctx); err != nil {
+		return err
+	}
+	return s.flusher.Err()
+}
+
+func (s *Store) flush(ctx context.Context) error {
+	s.writeLk.Lock()
+	defer s.writeLk.Unlock()
+
 	if s.writeBatch == nil {
 		return nil
 	}
-	_, err = s.writeBatch.Exec(ctx)
-	if err != nil {
-		return fmt.Errorf("batch exec: %w", err)
-	}
-	s.writeBatch = s.db.TxPipeline()
-	return nil
+	return s.writeBatch.Commit(ctx)
 }
 
 func (s *Store) Get(ctx context.Context, key []byte) (value []byte, err error) {
@@ -101,9 +137,43 @@ func (s *Store) Get(ctx context.Context, key []byte) (value []byte, err error) {
 	return dec, nil
 }
 
-func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
+func (s *Store) Has(ctx context.Context, key []byte) (exists bool, err error) {
+	n, err := s.db.Exists(ctx, store.Key(key).String()).Result()
+	if err != nil {
+		return false, fmt.Errorf("exists: %w", err)
+	}
+	return n > 0, nil
+}
+
+// BatchHas pipelines an EXISTS per key, as EXISTS only counts the existing keys when given several.
+func (s *Store) BatchHas(ctx context.Context, keys [][]byte) (exists []bool, err error) {
+	log.Debugw("batch has", "key_count", len(keys))
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err = s.db.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Exists(ctx, store.Key(key).String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exists: %w", err)
+	}
+
+	exists = make([]bool, len(keys))
+	for i, cmd := range cmds {
+		exists[i] = cmd.Val() > 0
+	}
+	return exists, nil
+}
+
+func (s *Store) BatchGet(ctx context.Context, keys [][]byte, options ...store.ReadOption) *store.Iterator {
 	log.Debugw("batch get", "key_count", len(keys))
 
+	var opts store.ReadOptions
+	for _, o := range options {
+		o.Apply(&opts)
+	}
+
 	var strKeys []string
 	for _, key := range keys {
 		strKeys = append(strKeys, store.Key(key).String())
@@ -127,13 +197,22 @@ func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
 					kr.PushError(fmt.Errorf("decompress: %w", err))
 					return
 				}
-				kr.PushItem(store.KV{
+				if !kr.PushItem(store.KV{
 					Key:   keys[i],
 					Value: dec,
-				})
+				}) {
+					return
+				}
 			case nil:
-				kr.PushError(store.ErrNotFound)
-				return
+				if !opts.AllowMissing {
+					kr.PushError(store.ErrNotFound)
+					return
+				}
+				if !kr.PushItem(store.KV{
+					Key: keys[i],
+				}) {
+					return
+				}
 			default:
 				kr.PushError(fmt.Errorf("unexpected type: %T", val))
 				return
@@ -146,50 +225,225 @@ func (s *Store) BatchGet(ctx context.Context, keys [][]byte) *store.Iterator {
 func (s *Store) Prefix(ctx context.Context, prefix []byte, limit int, options ...store.ReadOption) *store.Iterator {
 	log.Debugw("prefix", "prefix", store.Key(prefix), "limit", limit)
 
+	return s.orderedIterator(ctx, store.Key(prefix).String()+"*", nil, store.Limit(limit), options)
+}
+
+// ForEachPrefix iterates over the result of `Prefix`: the ordering is emulated the same way.
+func (s *Store) ForEachPrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error, options ...store.ReadOption) (err error) {
+	return store.ForEach(s.Prefix(ctx, prefix, 0, options...), fn)
+}
+
+func (s *Store) Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...store.ReadOption) *store.Iterator {
+	log.Debugw("scanning", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd), "limit", store.Limit(limit))
+
+	// Keys are hex encoded, which preserves the byte ordering, so bounds can be compared on the encoded form.
+	from, to := store.Key(start).String(), store.Key(exclusiveEnd).String()
+	inRange := func(key string) bool {
+		return key >= from && (to == "" || key < to)
+	}
+	return s.orderedIterator(ctx, scanPattern(from, to), inRange, store.Limit(limit), options)
+}
+
+// PrefixPage carries the SCAN cursor in the continuation token. Pages are unordered, so the
+// `Reverse()` option is ignored, and `pageSize` is a hint: SCAN returns keys by batches, so a page may
+// hold a few more keys. A key may be returned more than once while the keyspace is being rehashed.
+func (s *Store) PrefixPage(ctx context.Context, prefix []byte, pageSize int, token string, options ...store.ReadOption) (items []store.KV, next string, err error) {
+	log.Debugw("prefix paging", "prefix", store.Key(prefix), "page_size", pageSize)
+	if pageSize <= 0 {
+		return nil, "", fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	var opts store.ReadOptions
+	for _, o := range options {
+		o.Apply(&opts)
+	}
+
+	var cursor uint64
+	if token != "" {
+		cursor, err = strconv.ParseUint(token, 10, 64)
+		if err != nil || cursor == 0 {
+			return nil, "", fmt.Errorf("invalid continuation token %q", token)
+		}
+	}
+
+	var keys []string
+	for {
+		var batch []string
+		batch, cursor, err = s.db.Scan(ctx, cursor, store.Key(prefix).String()+"*", int64(pageSize)).Result()
+		if err != nil {
+			return nil, "", warpRedisError(err)
+		}
+		keys = append(keys, batch...)
+		if len(keys) >= pageSize || cursor == 0 {
+			break
+		}
+	}
+
+	var values []interface{}
+	if !opts.KeyOnly && len(keys) > 0 {
+		values, err = s.db.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, "", warpRedisError(err)
+		}
+	}
+
+	for i, k := range keys {
+		key, err := decodeKey(k)
+		if err != nil {
+			return nil, "", err
+		}
+
+		item := store.KV{Key: key}
+		if !opts.KeyOnly {
+			switch v := values[i].(type) {
+			case string:
+				item.Value, err = s.compression.Decompress([]byte(v))
+				if err != nil {
+					return nil, "", fmt.Errorf("decompress: %w", err)
+				}
+			case nil:
+				// deleted since the SCAN
+				continue
+			default:
+				return nil, "", fmt.Errorf("unexpected type: %T", v)
+			}
+		}
+		items = append(items, item)
+	}
+
+	if cursor != 0 {
+		next = strconv.FormatUint(cursor, 10)
+	}
+	return items, next, nil
+}
+
+// Count walks the matching keys with SCAN, which may return a key more than once while the keyspace
+// is being rehashed: the count is then approximate.
+func (s *Store) Count(ctx context.Context, prefix []byte) (count int64, err error) {
+	log.Debugw("counting", "prefix", store.Key(prefix))
+	sit := s.db.Scan(ctx, 0, store.Key(prefix).String()+"*", maxBatchLen).Iterator()
+	for sit.Next(ctx) {
+		count++
+	}
+	if err := sit.Err(); err != nil {
+		return 0, warpRedisError(err)
+	}
+	return count, nil
+}
+
+// orderedIterator emulates an ordered range read. Redis keys have no ordering, so every key matching
+// `pattern` (and `inRange` when not nil) is collected through SCAN and sorted before values are fetched
+// with MGET, `maxBatchLen` keys at a time.
+func (s *Store) orderedIterator(ctx context.Context, pattern string, inRange func(key string) bool, limit store.Limit, options []store.ReadOption) *store.Iterator {
 	var opts store.ReadOptions
 	for _, o := range options {
 		o.Apply(&opts)
 	}
+
 	kr := store.NewIterator(ctx)
 	go func() {
 		defer kr.PushFinished()
-		cmd := s.db.Scan(ctx, 0, store.Key(prefix).String(), int64(limit))
-		sit := cmd.Iterator()
-		for sit.Next(ctx) {
-			if sit.Err() != nil {
-				kr.PushError(warpRedisError(sit.Err()))
-				return
+		keys, err := s.scanKeys(ctx, pattern, inRange)
+		if err != nil {
+			kr.PushError(err)
+			return
+		}
+
+		if opts.Reverse {
+			sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+		} else {
+			sort.Strings(keys)
+		}
+		keys = dedupSorted(keys)
+		if limit.Bounded() && len(keys) > int(limit) {
+			keys = keys[:limit]
+		}
+
+		for len(keys) > 0 {
+			chunk := keys
+			if len(chunk) > maxBatchLen {
+				chunk = chunk[:maxBatchLen]
 			}
-			if opts.KeyOnly {
-				dec, err := s.compression.Decompress([]byte(sit.Val()))
+			keys = keys[len(chunk):]
+
+			var values []interface{}
+			if !opts.KeyOnly {
+				values, err = s.db.MGet(ctx, chunk...).Result()
 				if err != nil {
-					kr.PushError(fmt.Errorf("decompress: %w", err))
+					kr.PushError(warpRedisError(err))
 					return
 				}
-				kr.PushItem(store.KV{
-					Key: dec,
-				})
-			} else {
-				val, err := s.db.Get(ctx, sit.Val()).Bytes()
+			}
+
+			for i, k := range chunk {
+				key, err := decodeKey(k)
 				if err != nil {
-					kr.PushError(warpRedisError(err))
+					kr.PushError(err)
 					return
 				}
-				dec, err := s.compression.Decompress(val)
-				if err != nil {
-					kr.PushError(warpRedisError(err))
+
+				item := store.KV{Key: key}
+				if !opts.KeyOnly {
+					switch v := values[i].(type) {
+					case string:
+						item.Value, err = s.compression.Decompress([]byte(v))
+						if err != nil {
+							kr.PushError(fmt.Errorf("decompress: %w", err))
+							return
+						}
+					case nil:
+						// deleted since the SCAN
+						continue
+					default:
+						kr.PushError(fmt.Errorf("unexpected type: %T", v))
+						return
+					}
+				}
+
+				if !kr.PushItem(item) {
 					return
 				}
-				kr.PushItem(store.KV{
-					Key:   []byte(sit.Val()),
-					Value: dec,
-				})
 			}
 		}
 	}()
 	return kr
 }
 
+// scanKeys returns every key matching `pattern`, and `inRange` when not nil.
+func (s *Store) scanKeys(ctx context.Context, pattern string, inRange func(key string) bool) ([]string, error) {
+	var keys []string
+	sit := s.db.Scan(ctx, 0, pattern, maxBatchLen).Iterator()
+	for sit.Next(ctx) {
+		if inRange == nil || inRange(sit.Val()) {
+			keys = append(keys, sit.Val())
+		}
+	}
+	if err := sit.Err(); err != nil {
+		return nil, warpRedisError(err)
+	}
+	return keys, nil
+}
+
+// dedupSorted drops the duplicates SCAN may return while the keyspace is being rehashed.
+func dedupSorted(keys []string) []string {
+	out := keys[:0]
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// scanPattern narrows a SCAN to the common prefix of the encoded range bounds.
+func scanPattern(from, to string) string {
+	n := 0
+	for n < len(from) && n < len(to) && from[n] == to[n] {
+		n++
+	}
+	return from[:n] + "*"
+}
+
 func (s *Store) Delete(ctx context.Context, key []byte) (err error) {
 	log.Debugw("deleting", "key", store.Key(key))
 	err = s.db.Del(ctx, store.Key(key).String()).Err()
@@ -212,17 +466,73 @@ func (s *Store) BatchDelete(ctx context.Context, keys [][]byte) (err error) {
 	return nil
 }
 
+func (s *Store) DeletePrefix(ctx context.Context, prefix []byte) (err error) {
+	log.Debugw("prefix deletion", "prefix", store.Key(prefix))
+	return s.unlinkMatching(ctx, store.Key(prefix).String()+"*", nil)
+}
+
+func (s *Store) DeleteRange(ctx context.Context, start, exclusiveEnd []byte) (err error) {
+	log.Debugw("range deletion", "start", store.Key(start), "exclusive_end", store.Key(exclusiveEnd))
+	from, to := store.Key(start).String(), store.Key(exclusiveEnd).String()
+	inRange := func(key string) bool {
+		return key >= from && (to == "" || key < to)
+	}
+	return s.unlinkMatching(ctx, scanPattern(from, to), inRange)
+}
+
+// unlinkMatching walks the keys matching `pattern`, and `inRange` when not nil, with SCAN and UNLINKs
+// them `maxBatchLen` at a time.
+func (s *Store) unlinkMatching(ctx context.Context, pattern string, inRange func(key string) bool) error {
+	var batch []string
+	unlink := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.db.Unlink(ctx, batch...).Err(); err != nil {
+			return fmt.Errorf("unlink: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	sit := s.db.Scan(ctx, 0, pattern, maxBatchLen).Iterator()
+	for sit.Next(ctx) {
+		if inRange != nil && !inRange(sit.Val()) {
+			continue
+		}
+		if batch = append(batch, sit.Val()); len(batch) >= maxBatchLen {
+			if err := unlink(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sit.Err(); err != nil {
+		return warpRedisError(err)
+	}
+	return unlink()
+}
+
 func (s *Store) Close() error {
-	if s.writeBatch != nil && s.writeBatch.Len() > 0 {
+	s.flusher.Stop()
+	if s.writeBatch != nil {
 		if err := s.FlushPuts(context.TODO()); err != nil {
 			log.Errorf("flush puts: %s", err)
 		}
+		s.writeBatch.Discard()
 	}
 	return s.db.Close()
 }
 
 var _ store.Store = (*Store)(nil)
 
+func decodeKey(key string) ([]byte, error) {
+	out, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key %q: %w", key, err)
+	}
+	return out, nil
+}
+
 func warpRedisError(err error) error {
 	if err == redis.Nil {
 		return store.ErrNotFound
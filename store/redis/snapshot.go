@@ -0,0 +1,22 @@
+package redis
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+)
+
+var _ store.Snapshotter = (*Store)(nil)
+
+// Snapshot is best-effort: redis cannot serve reads from a past state, so the snapshot reads the live
+// data, and writes made while it is open are visible.
+func (s *Store) Snapshot(_ context.Context) (store.Snapshot, error) {
+	return &snapshot{Reader: s}, nil
+}
+
+type snapshot struct {
+	store.Reader
+}
+
+func (sn *snapshot) Close() error {
+	return nil
+}
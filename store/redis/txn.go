@@ -0,0 +1,119 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/go-redis/redis/v8"
+)
+
+var _ store.Transactional = (*Store)(nil)
+
+func (s *Store) Begin(ctx context.Context) (store.Txn, error) {
+	return &txn{
+		s:      s,
+		conn:   s.db.Conn(ctx),
+		writes: make(map[string]*txnWrite),
+	}, nil
+}
+
+// txn holds a dedicated connection on which every key read is WATCHed, and applies the buffered
+// writes in a MULTI/EXEC block, which redis aborts if a watched key changed.
+type txn struct {
+	s      *Store
+	conn   *redis.Conn
+	writes map[string]*txnWrite
+	order  []string
+}
+
+type txnWrite struct {
+	value   []byte
+	deleted bool
+}
+
+func (t *txn) Get(ctx context.Context, key []byte) (value []byte, err error) {
+	k := store.Key(key).String()
+	if w, ok := t.writes[k]; ok {
+		if w.deleted {
+			return nil, store.ErrNotFound
+		}
+		return w.value, nil
+	}
+
+	if err := t.conn.Process(ctx, redis.NewStatusCmd(ctx, "watch", k)); err != nil {
+		return nil, fmt.Errorf("watch: %w", err)
+	}
+
+	val, err := t.conn.Get(ctx, k).Bytes()
+	if err != nil {
+		return nil, warpRedisError(err)
+	}
+	dec, err := t.s.compression.Decompress(val)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	return dec, nil
+}
+
+func (t *txn) Put(_ context.Context, key, value []byte) (err error) {
+	t.write(store.Key(key).String(), &txnWrite{value: value})
+	return nil
+}
+
+func (t *txn) Delete(_ context.Context, key []byte) (err error) {
+	t.write(store.Key(key).String(), &txnWrite{deleted: true})
+	return nil
+}
+
+func (t *txn) write(k string, w *txnWrite) {
+	if _, ok := t.writes[k]; !ok {
+		t.order = append(t.order, k)
+	}
+	t.writes[k] = w
+}
+
+func (t *txn) Commit(ctx context.Context) (err error) {
+	// Without writes there is no MULTI/EXEC to clear the WATCHes, which would abort the next one
+	// executed on the connection once back in the pool
+	if len(t.order) == 0 {
+		return t.Rollback()
+	}
+	defer t.close()
+
+	_, err = t.conn.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, k := range t.order {
+			w := t.writes[k]
+			if w.deleted {
+				pipe.Del(ctx, k)
+			} else {
+				pipe.Set(ctx, k, t.s.compression.Compress(w.value), 0)
+			}
+		}
+		return nil
+	})
+	if err == redis.TxFailedErr {
+		return store.ErrConflict
+	}
+	if err != nil {
+		return fmt.Errorf("exec: %w", err)
+	}
+	return nil
+}
+
+func (t *txn) Rollback() (err error) {
+	if t.conn == nil {
+		return nil
+	}
+	defer t.close()
+
+	ctx := context.Background()
+	return t.conn.Process(ctx, redis.NewStatusCmd(ctx, "unwatch"))
+}
+
+// close releases the connection back to the pool.
+func (t *txn) close() {
+	if err := t.conn.Close(); err != nil {
+		log.Errorf("close txn connection: %s", err)
+	}
+	t.conn = nil
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+	"strings"
+)
+
+var _ store.Watcher = (*Store)(nil)
+
+// Watch relies on keyspace notifications, which must be enabled on the server with at least the
+// `K$gx` flags of `notify-keyspace-events` (keyspace events for string, generic and expiration
+// commands). Notifications are delivered at most once, and the value of a put is read when the
+// notification is received, so it may be more recent than the change, or nil when the key was deleted
+// in the meantime.
+func (s *Store) Watch(ctx context.Context, prefix []byte) <-chan store.Event {
+	log.Debugw("watching", "prefix", store.Key(prefix))
+	events := make(chan store.Event, 100)
+
+	channelPrefix := fmt.Sprintf("__keyspace@%d__:", s.db.Options().DB)
+	pubSub := s.db.PSubscribe(ctx, channelPrefix+store.Key(prefix).String()+"*")
+
+	// Wait for the subscription to be confirmed, so that the changes made after the call are notified
+	if _, err := pubSub.Receive(ctx); err != nil {
+		log.Errorf("watch %s: %s", store.Key(prefix), err)
+		_ = pubSub.Close()
+		close(events)
+		return events
+	}
+
+	go func() {
+		defer close(events)
+		defer pubSub.Close()
+
+		messages := pubSub.Channel()
+		for {
+			var ev store.Event
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				key, err := decodeKey(strings.TrimPrefix(msg.Channel, channelPrefix))
+				if err != nil {
+					log.Errorf("watch %s: %s", store.Key(prefix), err)
+					continue
+				}
+
+				switch msg.Payload {
+				case "del", "expired", "evicted":
+					ev = store.Event{Type: store.EventDelete, Key: key}
+				case "expire", "persist":
+					// only the expiration of the key changed
+					continue
+				default:
+					value, err := s.Get(ctx, key)
+					if err == store.ErrNotFound {
+						// the key was deleted since, which is notified next
+						value, err = nil, nil
+					}
+					if err != nil {
+						log.Errorf("watch %s: %s", store.Key(prefix), err)
+						return
+					}
+					ev = store.Event{Type: store.EventPut, Key: key, Value: value}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case events <- ev:
+			}
+		}
+	}()
+
+	return events
+}
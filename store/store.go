@@ -4,22 +4,27 @@ import "context"
 
 type Store interface {
 	// Put writes to a transaction, which might be flushed from time to time. Call FlushPuts() to ensure all Put entries are properly written to the database.
-	Put(ctx context.Context, key, value []byte) (err error)
+	Put(ctx context.Context, key, value []byte, options ...WriteOption) (err error)
 	// FlushPuts takes any pending writes (calls to Put()), and flushes them.
 	FlushPuts(ctx context.Context) (err error)
+	// NewBatch returns a batch of writes independent from the pending writes of Put() and from the other batches.
+	NewBatch() Batch
 
-	// Get a given key.  Returns `kdb.ErrNotFound` if not found.
-	Get(ctx context.Context, key []byte) (value []byte, err error)
-	// BatchGet get a batch of keys.  Returns `kdb.ErrNotFound` the first time a key is not found: not finding a key is fatal and interrupts the result set from being fetched completely.  BatchGet guarantees that Iterator return results in the exact same order as keys
-	BatchGet(ctx context.Context, keys [][]byte) *Iterator
+	Reader
 
-	Prefix(ctx context.Context, prefix []byte, limit int, options ...ReadOption) *Iterator
+	// PrefixPage returns a page of at most `pageSize` keys starting with `prefix`, for callers which cannot keep an Iterator open between calls.  The first page is requested with an empty `token`, the following ones with the `next` token returned by the previous page, which is empty once the last page is reached.
+	PrefixPage(ctx context.Context, prefix []byte, pageSize int, token string, options ...ReadOption) (items []KV, next string, err error)
 
 	// Delete a given key.  Returns `kdb.ErrNotFound` if not found.
 	Delete(ctx context.Context, key []byte) (err error)
 
 	BatchDelete(ctx context.Context, keys [][]byte) (err error)
 
+	// DeletePrefix deletes every key starting with `prefix`.
+	DeletePrefix(ctx context.Context, prefix []byte) (err error)
+	// DeleteRange deletes every key in the range [start, exclusiveEnd).  An empty `exclusiveEnd` deletes up to the end of the keyspace.
+	DeleteRange(ctx context.Context, start, exclusiveEnd []byte) (err error)
+
 	// Close the underlying store engine and clear up any resources currently hold
 	// by this instance.
 	//
@@ -27,3 +32,63 @@ type Store interface {
 	// and cannot be reliably used to perform read/write operation on the backing engine.
 	Close() error
 }
+
+// Reader holds the read operations of a Store.
+type Reader interface {
+	// Get a given key.  Returns `kdb.ErrNotFound` if not found.
+	Get(ctx context.Context, key []byte) (value []byte, err error)
+	// Has tells whether a given key exists, without fetching its value.
+	Has(ctx context.Context, key []byte) (exists bool, err error)
+	// BatchHas tells whether each of the given keys exists, in the same order as keys.
+	BatchHas(ctx context.Context, keys [][]byte) (exists []bool, err error)
+
+	// BatchGet get a batch of keys.  Returns `kdb.ErrNotFound` the first time a key is not found: not finding a key is fatal and interrupts the result set from being fetched completely, unless the `AllowMissing()` option is given.  BatchGet guarantees that Iterator return results in the exact same order as keys
+	BatchGet(ctx context.Context, keys [][]byte, options ...ReadOption) *Iterator
+
+	// Prefix returns the keys starting with `prefix` in ascending order, or descending order with the `Reverse()` option, stopping after `limit` entries when `limit` is greater than 0.
+	Prefix(ctx context.Context, prefix []byte, limit int, options ...ReadOption) *Iterator
+
+	// ForEachPrefix calls `fn` on the keys starting with `prefix`, in the same order as `Prefix`, from the calling goroutine.  The key and value given to `fn` are only valid until it returns, and must be copied to be kept.  Iteration stops at the first error returned by `fn`, which is returned as is.
+	ForEachPrefix(ctx context.Context, prefix []byte, fn func(key, value []byte) error, options ...ReadOption) (err error)
+
+	// Count returns the number of keys starting with `prefix`, without fetching their values.
+	Count(ctx context.Context, prefix []byte) (count int64, err error)
+
+	// Scan returns the keys in the range [start, exclusiveEnd) in ascending order, or descending order with the `Reverse()` option, stopping after `limit` entries when `limit` is greater than 0.  An empty `exclusiveEnd` scans up to the end of the keyspace.
+	Scan(ctx context.Context, start, exclusiveEnd []byte, limit int, options ...ReadOption) *Iterator
+}
+
+// Batch holds writes until they are committed together.  A batch is not safe for concurrent use, but
+// several batches can be used concurrently.
+type Batch interface {
+	Put(ctx context.Context, key, value []byte, options ...WriteOption) (err error)
+	Delete(ctx context.Context, key []byte) (err error)
+
+	// Len returns the number of writes held by the batch.
+	Len() int
+	// Size returns the size in bytes of the keys and values held by the batch.
+	Size() int
+
+	// Commit applies the writes held by the batch, and empties it so that it can be reused.  When it
+	// fails, the batch keeps its writes so that Commit can be retried: some of them may be applied
+	// already, unless the backend applies a batch atomically.
+	Commit(ctx context.Context) (err error)
+	// Discard drops the writes which are not committed yet, and releases the batch.
+	Discard()
+}
+
+// Snapshotter is implemented by stores able to serve reads from a point-in-time view.
+type Snapshotter interface {
+	// Snapshot opens a view of the store as of now: the reads made through it do not see the writes
+	// made afterwards. The snapshot must be closed once done with.
+	Snapshot(ctx context.Context) (Snapshot, error)
+}
+
+// Snapshot is a `Reader` over a point-in-time view of a store.
+type Snapshot interface {
+	Reader
+
+	// Close releases the view.  Iterators obtained from the snapshot must be consumed or have their
+	// context cancelled beforehand.
+	Close() error
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	maxTxnAttempts = 10
+	minTxnBackoff  = 5 * time.Millisecond
+	maxTxnBackoff  = 500 * time.Millisecond
+)
+
+// Transactional is implemented by stores able to apply several reads and writes atomically.
+type Transactional interface {
+	// Begin starts a read-write transaction, which must be ended by either `Commit` or `Rollback`.
+	Begin(ctx context.Context) (Txn, error)
+}
+
+// Txn is an optimistic transaction: writes are held until `Commit`, which fails with `ErrConflict`
+// when a key read through the transaction has been modified in the meantime.
+//
+// A Txn is not safe for concurrent use.
+type Txn interface {
+	// Get a given key, seeing the writes of the transaction.  Returns `ErrNotFound` if not found.
+	Get(ctx context.Context, key []byte) (value []byte, err error)
+	Put(ctx context.Context, key, value []byte) (err error)
+	Delete(ctx context.Context, key []byte) (err error)
+
+	// Commit applies the writes of the transaction.  Returns `ErrConflict` if a key read by the
+	// transaction changed, in which case nothing was written.
+	Commit(ctx context.Context) (err error)
+	// Rollback discards the transaction.  It is a no-op after `Commit`.
+	Rollback() (err error)
+}
+
+// RunTxn runs fn in a transaction of st and commits it, starting over with a new transaction when
+// the commit conflicts. The transaction is rolled back when fn returns an error, which is returned as is.
+func RunTxn(ctx context.Context, st Transactional, fn func(txn Txn) error) error {
+	return retryOnConflict(ctx, maxTxnAttempts, func() error {
+		txn, err := st.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("begin: %w", err)
+		}
+		defer txn.Rollback()
+
+		if err := fn(txn); err != nil {
+			return err
+		}
+		return txn.Commit(ctx)
+	})
+}
+
+// RetryOnConflict calls fn as long as it fails with `ErrConflict`, until ctx is done, sleeping an
+// exponential backoff between attempts.
+func RetryOnConflict(ctx context.Context, fn func() error) error {
+	return retryOnConflict(ctx, 0, fn)
+}
+
+// retryOnConflict calls fn as long as it fails with `ErrConflict`, up to maxAttempts times or until
+// ctx is done when 0, sleeping an exponential backoff with jitter between attempts.
+func retryOnConflict(ctx context.Context, maxAttempts int, fn func() error) error {
+	backoff := minTxnBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+		if attempt == maxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		// The jitter spreads the retries of the writers which conflicted together
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+
+		if backoff *= 2; backoff > maxTxnBackoff {
+			backoff = maxTxnBackoff
+		}
+	}
+}
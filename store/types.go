@@ -1,6 +1,7 @@
 package store
 
 import (
+	"bytes"
 	"encoding/hex"
 	"strconv"
 )
@@ -32,6 +33,26 @@ func (k Key) String() string {
 	return hex.EncodeToString(k)
 }
 
+// BeforeEnd reports whether key sorts before exclusiveEnd. An empty exclusiveEnd
+// means the range is open-ended and every key is before it.
+func BeforeEnd(key, exclusiveEnd []byte) bool {
+	return len(exclusiveEnd) == 0 || bytes.Compare(key, exclusiveEnd) < 0
+}
+
+// PrefixEnd returns the smallest key sorting after every key that starts with prefix,
+// or nil when there is none (empty prefix or prefix made only of 0xff bytes).
+func PrefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
 type Limit int
 
 func (l Limit) Reached(count uint64) bool {
@@ -0,0 +1,13 @@
+package store
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("ab"), PrefixEnd([]byte("aa")))
+	assert.Equal(t, []byte("b"), PrefixEnd([]byte("a\xff")))
+	assert.Nil(t, PrefixEnd([]byte("\xff\xff")))
+	assert.Nil(t, PrefixEnd(nil))
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// Version is an opaque token identifying the state of a key, as returned by `GetVersioned`. It is
+// only meaningful to the store which issued it.
+type Version []byte
+
+// Versioned is implemented by stores supporting conditional writes. Conditional writes are applied
+// immediately, and do not go through the `Put` write batch.
+type Versioned interface {
+	// GetVersioned a given key along with its current version.  Returns `ErrNotFound` if not found.
+	GetVersioned(ctx context.Context, key []byte) (value []byte, version Version, err error)
+	// CompareAndSwap writes value if the key is still at the `expected` version.  Returns `ErrConflict`
+	// if it changed or was deleted.
+	CompareAndSwap(ctx context.Context, key []byte, expected Version, value []byte) (err error)
+	// PutIfAbsent writes value if the key does not exist.  Returns `ErrConflict` if it does.
+	PutIfAbsent(ctx context.Context, key, value []byte) (err error)
+}
+
+// Update applies a read-modify-write of key: fn is given the current value (`exists` being false
+// when the key is not found) and returns the value to write, which is conditioned on the key not
+// having changed since it was read. On concurrent modification, the whole cycle starts over, up to
+// `maxTxnAttempts` times with an exponential backoff, so fn may be called several times.
+//
+// An error returned by fn aborts the update and is returned as is.
+func Update(ctx context.Context, st Versioned, key []byte, fn func(old []byte, exists bool) ([]byte, error)) error {
+	return update(ctx, st, key, maxTxnAttempts, fn)
+}
+
+// update is `Update` giving up after maxAttempts, or retrying until ctx is done when 0.
+func update(ctx context.Context, st Versioned, key []byte, maxAttempts int, fn func(old []byte, exists bool) ([]byte, error)) error {
+	return retryOnConflict(ctx, maxAttempts, func() error {
+		old, version, err := st.GetVersioned(ctx, key)
+		exists := err == nil
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		value, err := fn(old, exists)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return st.PutIfAbsent(ctx, key, value)
+		}
+		return st.CompareAndSwap(ctx, key, version, value)
+	})
+}
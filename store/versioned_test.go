@@ -0,0 +1,92 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+// memVersioned is an in-memory Versioned using an incrementing counter as version, with a hook called
+// before every conditional write.
+type memVersioned struct {
+	values   map[string][]byte
+	versions map[string]int
+	rev      int
+	onWrite  func()
+}
+
+func newMemVersioned() *memVersioned {
+	return &memVersioned{values: make(map[string][]byte), versions: make(map[string]int)}
+}
+
+func (m *memVersioned) put(key, value []byte) {
+	m.rev++
+	m.values[string(key)] = value
+	m.versions[string(key)] = m.rev
+}
+
+func (m *memVersioned) GetVersioned(_ context.Context, key []byte) ([]byte, Version, error) {
+	v, ok := m.values[string(key)]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	return v, Version(strconv.Itoa(m.versions[string(key)])), nil
+}
+
+func (m *memVersioned) CompareAndSwap(_ context.Context, key []byte, expected Version, value []byte) error {
+	if m.onWrite != nil {
+		m.onWrite()
+	}
+	if _, ok := m.values[string(key)]; !ok || !bytes.Equal(expected, []byte(strconv.Itoa(m.versions[string(key)]))) {
+		return ErrConflict
+	}
+	m.put(key, value)
+	return nil
+}
+
+func (m *memVersioned) PutIfAbsent(_ context.Context, key, value []byte) error {
+	if m.onWrite != nil {
+		m.onWrite()
+	}
+	if _, ok := m.values[string(key)]; ok {
+		return ErrConflict
+	}
+	m.put(key, value)
+	return nil
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.TODO()
+	st := newMemVersioned()
+	key := []byte("counter")
+
+	incr := func(old []byte, exists bool) ([]byte, error) {
+		n := 0
+		if exists {
+			n, _ = strconv.Atoi(string(old))
+		}
+		return []byte(strconv.Itoa(n + 1)), nil
+	}
+
+	require.NoError(t, Update(ctx, st, key, incr))
+	require.Equal(t, []byte("1"), st.values["counter"])
+
+	// a concurrent writer slips in before the first write attempt
+	calls := 0
+	st.onWrite = func() {
+		if calls++; calls == 1 {
+			st.put(key, []byte("10"))
+		}
+	}
+	require.NoError(t, Update(ctx, st, key, incr))
+	require.Equal(t, []byte("11"), st.values["counter"])
+	require.Equal(t, 2, calls)
+
+	// always conflicting
+	st.onWrite = func() {
+		st.put(key, []byte("0"))
+	}
+	require.ErrorIs(t, Update(ctx, st, key, incr), ErrConflict)
+}
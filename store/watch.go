@@ -0,0 +1,37 @@
+package store
+
+import "context"
+
+type EventType int
+
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a change of a key. `Value` is nil for deletions.
+type Event struct {
+	Type       EventType
+	Key, Value []byte
+	// Revision orders the changes when the store keeps one, it is 0 otherwise.
+	Revision int64
+}
+
+// Watcher is implemented by stores able to notify changes.
+type Watcher interface {
+	// Watch streams the changes made to keys starting with prefix, until ctx is done or the watch
+	// fails, at which point the channel is closed. Changes made before the call are not streamed, the
+	// ones made after it returns are unless the backend documents otherwise.
+	Watch(ctx context.Context, prefix []byte) <-chan Event
+}
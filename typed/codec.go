@@ -0,0 +1,143 @@
+package typed
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec converts values of type T from and to bytes.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+type jsonCodec[T any] struct{}
+
+// JSON encodes values with `encoding/json`.
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+func (jsonCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec[T]) Decode(data []byte) (v T, err error) {
+	err = json.Unmarshal(data, &v)
+	return v, err
+}
+
+type cborCodec[T any] struct{}
+
+// CBOR encodes values in the CBOR format, using the core deterministic encoding.
+func CBOR[T any]() Codec[T] {
+	return cborCodec[T]{}
+}
+
+var cborEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+func (cborCodec[T]) Encode(v T) ([]byte, error) {
+	return cborEncMode.Marshal(v)
+}
+
+func (cborCodec[T]) Decode(data []byte) (v T, err error) {
+	err = cbor.Unmarshal(data, &v)
+	return v, err
+}
+
+type gobCodec[T any] struct{}
+
+// Gob encodes values with `encoding/gob`.  Each value is encoded along with its type description, so
+// it can be decoded on its own.
+func Gob[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+func (gobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[T]) Decode(data []byte) (v T, err error) {
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+type protoCodec[T proto.Message] struct{}
+
+// Proto encodes protobuf messages, T being a generated message pointer type such as `*pb.Sector`.
+func Proto[T proto.Message]() Codec[T] {
+	return protoCodec[T]{}
+}
+
+func (protoCodec[T]) Encode(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (protoCodec[T]) Decode(data []byte) (v T, err error) {
+	// Generated messages describe their type even through a nil pointer
+	msg := v.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return v, err
+	}
+	return msg.(T), nil
+}
+
+type stringCodec struct{}
+
+// String stores strings as is, keeping their ordering.
+func String() Codec[string] {
+	return stringCodec{}
+}
+
+func (stringCodec) Encode(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (stringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+type bytesCodec struct{}
+
+// Bytes stores byte slices as is.
+func Bytes() Codec[[]byte] {
+	return bytesCodec{}
+}
+
+func (bytesCodec) Encode(v []byte) ([]byte, error) {
+	return v, nil
+}
+
+func (bytesCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type uint64Codec struct{}
+
+// Uint64 encodes integers as 8 bytes big-endian, so that keys are ordered numerically, as needed by
+// height indexed keys.
+func Uint64() Codec[uint64] {
+	return uint64Codec{}
+}
+
+func (uint64Codec) Encode(v uint64) ([]byte, error) {
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, v)
+	return out, nil
+}
+
+func (uint64Codec) Decode(data []byte) (uint64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid uint64 length %d", len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
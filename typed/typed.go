@@ -0,0 +1,167 @@
+// Package typed stores Go values in a store.Store, converting keys and values with codecs, so that every
+// reader and writer of a keyspace agrees on its encoding.
+package typed
+
+import (
+	"context"
+	"fmt"
+	"github.com/bitrainforest/kdb/store"
+)
+
+// Store reads and writes keys of type K holding values of type V.
+type Store[K, V any] struct {
+	st         store.Store
+	keyCodec   Codec[K]
+	valueCodec Codec[V]
+}
+
+// New returns a Store over st, encoding keys with keyCodec and values with valueCodec.  The ordering of
+// keys follows their encoding, see `Uint64()` for ordered integer keys.
+func New[K, V any](st store.Store, keyCodec Codec[K], valueCodec Codec[V]) *Store[K, V] {
+	return &Store[K, V]{
+		st:         st,
+		keyCodec:   keyCodec,
+		valueCodec: valueCodec,
+	}
+}
+
+func (s *Store[K, V]) encodeKey(key K) ([]byte, error) {
+	k, err := s.keyCodec.Encode(key)
+	if err != nil {
+		return nil, fmt.Errorf("encode key: %w", err)
+	}
+	return k, nil
+}
+
+// Get a given key.  Returns `kdb.ErrNotFound` if not found.
+func (s *Store[K, V]) Get(ctx context.Context, key K) (value V, err error) {
+	k, err := s.encodeKey(key)
+	if err != nil {
+		return value, err
+	}
+
+	data, err := s.st.Get(ctx, k)
+	if err != nil {
+		return value, err
+	}
+
+	value, err = s.valueCodec.Decode(data)
+	if err != nil {
+		return value, fmt.Errorf("decode value of %s: %w", store.Key(k), err)
+	}
+	return value, nil
+}
+
+// Has tells whether a given key exists.
+func (s *Store[K, V]) Has(ctx context.Context, key K) (exists bool, err error) {
+	k, err := s.encodeKey(key)
+	if err != nil {
+		return false, err
+	}
+	return s.st.Has(ctx, k)
+}
+
+// Put writes a value, see `store.Store.Put`.
+func (s *Store[K, V]) Put(ctx context.Context, key K, value V, options ...store.WriteOption) (err error) {
+	k, err := s.encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.valueCodec.Encode(value)
+	if err != nil {
+		return fmt.Errorf("encode value of %s: %w", store.Key(k), err)
+	}
+	return s.st.Put(ctx, k, data, options...)
+}
+
+// FlushPuts flushes the pending writes of the underlying store.
+func (s *Store[K, V]) FlushPuts(ctx context.Context) (err error) {
+	return s.st.FlushPuts(ctx)
+}
+
+// Delete a given key.  Returns `kdb.ErrNotFound` if not found.
+func (s *Store[K, V]) Delete(ctx context.Context, key K) (err error) {
+	k, err := s.encodeKey(key)
+	if err != nil {
+		return err
+	}
+	return s.st.Delete(ctx, k)
+}
+
+// Prefix returns the entries which encoded key starts with `prefix`, see `store.Store.Prefix`.
+func (s *Store[K, V]) Prefix(ctx context.Context, prefix []byte, limit int, options ...store.ReadOption) *Iterator[K, V] {
+	readOptions := store.ReadOptions{}
+	for _, opt := range options {
+		opt.Apply(&readOptions)
+	}
+
+	return &Iterator[K, V]{
+		it:      s.st.Prefix(ctx, prefix, limit, options...),
+		s:       s,
+		keyOnly: readOptions.KeyOnly,
+	}
+}
+
+// Iterator decodes the entries of a store.Iterator.
+type Iterator[K, V any] struct {
+	it      *store.Iterator
+	s       *Store[K, V]
+	keyOnly bool
+
+	key   K
+	value V
+	err   error
+}
+
+// Next moves to the next entry, returning false at the end of the iteration or on failure.
+func (it *Iterator[K, V]) Next() bool {
+	if it.err != nil || !it.it.Next() {
+		return false
+	}
+
+	item := it.it.Item()
+	key, err := it.s.keyCodec.Decode(item.Key)
+	if err != nil {
+		return it.fail(fmt.Errorf("decode key %s: %w", store.Key(item.Key), err))
+	}
+
+	var value V
+	if !it.keyOnly {
+		value, err = it.s.valueCodec.Decode(item.Value)
+		if err != nil {
+			return it.fail(fmt.Errorf("decode value of %s: %w", store.Key(item.Key), err))
+		}
+	}
+
+	it.key, it.value = key, value
+	return true
+}
+
+func (it *Iterator[K, V]) fail(err error) bool {
+	it.err = err
+	it.it.Close()
+	return false
+}
+
+// Key returns the key of the current entry.
+func (it *Iterator[K, V]) Key() K {
+	return it.key
+}
+
+// Value returns the value of the current entry, the zero value with the `KeyOnly()` option.
+func (it *Iterator[K, V]) Value() V {
+	return it.value
+}
+
+func (it *Iterator[K, V]) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.it.Err()
+}
+
+// Close stops the iteration, see `store.Iterator.Close`.
+func (it *Iterator[K, V]) Close() {
+	it.it.Close()
+}
@@ -0,0 +1,99 @@
+package typed
+
+import (
+	"context"
+	"github.com/bitrainforest/kdb/store"
+	"github.com/bitrainforest/kdb/store/badger"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io/ioutil"
+	"testing"
+)
+
+type sector struct {
+	Miner string
+	Size  uint64
+	Tags  []string
+}
+
+func makeStore(t *testing.T) store.Store {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "badger")
+	require.NoError(t, err)
+	st, err := badger.NewStore(dir)
+	require.NoError(t, err)
+	return st
+}
+
+func TestCodecs(t *testing.T) {
+	value := sector{Miner: "f01234", Size: 32 << 30, Tags: []string{"sealed"}}
+	for name, codec := range map[string]Codec[sector]{
+		"json": JSON[sector](),
+		"cbor": CBOR[sector](),
+		"gob":  Gob[sector](),
+	} {
+		data, err := codec.Encode(value)
+		require.NoError(t, err, name)
+		decoded, err := codec.Decode(data)
+		require.NoError(t, err, name)
+		require.Equal(t, value, decoded, name)
+	}
+
+	codec := Proto[*wrapperspb.StringValue]()
+	data, err := codec.Encode(wrapperspb.String("f01234"))
+	require.NoError(t, err)
+	msg, err := codec.Decode(data)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(wrapperspb.String("f01234"), msg))
+
+	key, err := Uint64().Encode(258)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 0, 0, 0, 1, 2}, key)
+	_, err = Uint64().Decode([]byte{1})
+	require.Error(t, err)
+}
+
+func TestStore(t *testing.T) {
+	st := makeStore(t)
+	ctx := context.TODO()
+
+	sectors := New[uint64, sector](st, Uint64(), CBOR[sector]())
+	for i := uint64(1); i <= 3; i++ {
+		require.NoError(t, sectors.Put(ctx, i*100, sector{Miner: "f01234", Size: i}))
+	}
+	require.NoError(t, sectors.FlushPuts(ctx))
+
+	value, err := sectors.Get(ctx, 200)
+	require.NoError(t, err)
+	require.Equal(t, sector{Miner: "f01234", Size: 2}, value)
+	_, err = sectors.Get(ctx, 400)
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	var keys []uint64
+	it := sectors.Prefix(ctx, nil, 0, store.Reverse())
+	for it.Next() {
+		keys = append(keys, it.Key())
+		require.Equal(t, it.Key()/100, it.Value().Size)
+	}
+	require.NoError(t, it.Err())
+	require.Equal(t, []uint64{300, 200, 100}, keys)
+
+	// A value written with another encoding fails to decode
+	require.NoError(t, st.Put(ctx, []byte{0, 0, 0, 0, 0, 0, 1, 244}, []byte("{}")))
+	require.NoError(t, st.FlushPuts(ctx))
+	_, err = sectors.Get(ctx, 500)
+	require.Error(t, err)
+	it = sectors.Prefix(ctx, nil, 0)
+	for it.Next() {
+	}
+	require.Error(t, it.Err())
+
+	require.NoError(t, sectors.Delete(ctx, 100))
+	exists, err := sectors.Has(ctx, 100)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, st.Close())
+}